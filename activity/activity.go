@@ -56,8 +56,96 @@ var (
 	// gcPauseRE is the regular expression that matches ART garbage collection pauses.
 	// e.g. "Explicit concurrent mark sweep GC freed 706(30KB) AllocSpace objects, 0(0B) LOS objects, 40% free, 16MB/26MB, paused 632us total 52.753ms"
 	gcPauseRE = regexp.MustCompile(`(?P<type>(Background partial|Background sticky|Explicit))` + ` concurrent mark sweep GC.*paused\s+` + `(?P<pausedDur>[^\s]+)`)
+
+	// thermalShutdownRE is the regular expression that matches ThermalManagerService triggering a thermal
+	// shutdown, or ShutdownThread shutting down or rebooting for a thermal reason.
+	// e.g. "Thermal shutdown triggered: skin temperature 68C", "reboot reason: thermal" or "Rebooting, reason: shutdown,thermal"
+	thermalShutdownRE = regexp.MustCompile(`(?i)^(?:thermal shutdown triggered\b|(?:reboot|rebooting|shutdown|shutting down),?\s+reason:\s*(?:shutdown,)?thermal\b)`)
+
+	// modemResetRE is the regular expression that matches the RIL logging the radio becoming unavailable or the modem being reset.
+	// e.g. "[3456]< RADIO_POWER RADIO_UNAVAILABLE" or "Modem reset detected, reason: SSR"
+	modemResetRE = regexp.MustCompile(`(?i)\bRADIO_UNAVAILABLE\b|\bmodem (?:reset|restart|crash(?:ed)?)\b`)
+
+	// bootCompletedRE is the regular expression that matches init, which runs the property service, logging the
+	// sys.boot_completed property being set, which marks the end of boot.
+	// e.g. "processing action (sys.boot_completed=1) from (/system/etc/init/bootstat.rc:75)"
+	bootCompletedRE = regexp.MustCompile(`\bsys\.boot_completed\s*[=:]\s*\[?1\b`)
+
+	// dozeWhitelistRE is the regular expression that matches DeviceIdleController logging an app
+	// being exempted from doze and battery optimizations. Removals from the whitelist, e.g.
+	// "Removing com.example.app from whitelist", are not matched.
+	// e.g. "Doze: com.google.android.apps.fitness whitelisted" or "Adding com.example.app to user whitelist"
+	dozeWhitelistRE = regexp.MustCompile(`^(?:Doze:|Adding)\s+(?P<package>[a-zA-Z]\w*(?:\.\w+)+)\s+(?:whitelisted\b|to\s+(?:[\w-]+\s+)*whitelist\b)`)
+
+	// forceStopRE is the regular expression that matches ActivityManager force stopping a package.
+	// e.g. "Force stopping com.example.app appid=10055 user=0: from pid 1234"
+	forceStopRE = regexp.MustCompile(`^Force stopping (?P<package>\S+)(?:\s+appid=(?P<appid>\d+))?`)
+
+	// displayedRE is the regular expression that matches ActivityManager reporting the launch latency of an activity.
+	// e.g. "Displayed com.example.app/.MainActivity: +1s234ms" or "Displayed com.example.app/.MainActivity: +412ms (total +1s2ms)"
+	displayedRE = regexp.MustCompile(`^Displayed (?P<component>[^:\s]+): \+(?P<latency>[\dhms]+)`)
+
+	// locationRequestRE is the regular expression that matches LocationManagerService logging a location request.
+	// e.g. "requestLocationUpdates: provider=gps interval=1000 by com.example.app"
+	locationRequestRE = regexp.MustCompile(`requestLocationUpdates.*\bby\s+(?P<package>[\w.]+)`)
+
+	// locationIntervalRE is the regular expression that matches the interval of a location request.
+	// e.g. "interval=1000" or "interval=+1s0ms"
+	locationIntervalRE = regexp.MustCompile(`\binterval=(?P<interval>\+?[\w.]+)`)
+
+	// networkLostRE is the regular expression that matches ConnectivityService reporting a network being lost.
+	// e.g. "NetworkAgentInfo [WIFI () - 100] lost" or "NetworkAgentInfo [MOBILE (LTE) - 101] lost"
+	networkLostRE = regexp.MustCompile(`NetworkAgentInfo \[(?P<transport>\w+)[^\]]*\].*\blost\b`)
+
+	// networkInterfaceRE is the regular expression that matches a network interface going up or down.
+	// e.g. "interface wlan0 is up" or "Interface rmnet_data0 down"
+	networkInterfaceRE = regexp.MustCompile(`(?i)interface\s+(?P<iface>(?:wlan|rmnet|eth)\w*)\s+(?:is\s+)?(?P<state>up|down)\b`)
+
+	// enqueueNotificationRE is the regular expression that matches NotificationService logging a notification being posted.
+	// e.g. "enqueueNotificationInternal: pkg=com.example.app id=1 notification=Notification(...)"
+	enqueueNotificationRE = regexp.MustCompile(`enqueueNotification\w*.*\bpkg=(?P<package>[\w.]+)`)
+
+	// userActivityRE is the regular expression that matches PowerManagerService logging a user activity poke.
+	// e.g. "userActivityNoUpdateLocked: eventTime=123456, event=2, flags=0x0, uid=1000"
+	userActivityRE = regexp.MustCompile(`userActivity\w*:.*\bevent=(?P<event>\d+)(?:.*\buid=(?P<uid>\d+))?`)
+
+	// vpnEstablishedRE is the regular expression that matches Vpn logging a VPN tunnel being established by an app.
+	// e.g. "Established by com.example.vpn on tun0"
+	vpnEstablishedRE = regexp.MustCompile(`^Established by (?P<package>[a-zA-Z]\w*(?:\.\w+)+)`)
+
+	// vpnDisconnectedRE is the regular expression that matches Vpn logging a VPN tunnel being torn down.
+	// e.g. "setting state=DISCONNECTED, reason=agentDisconnect"
+	vpnDisconnectedRE = regexp.MustCompile(`\bstate=DISCONNECTED\b`)
+
+	// wifiScanRequestRE is the regular expression that matches WifiService logging an app requesting a WiFi scan.
+	// Older versions only log the UID.
+	// e.g. "startScan uid=10061 packageName=com.example.app" or "startScan uid=10061"
+	wifiScanRequestRE = regexp.MustCompile(`\bstartScan\b(?:.*\buid=(?P<uid>\d+))?(?:.*\b(?:packageName|package|pkg)=(?P<package>[\w.]+))?`)
+
+	// killAdjRE is the regular expression that matches the oom_adj of a process killed by ActivityManager.
+	// e.g. "Killing 30363:com.google.android.apps.plus/u0a206 (adj 906): empty #17"
+	killAdjRE = regexp.MustCompile(`\(adj (?P<adj>-?\d+)\)`)
+
+	// mediaSessionRE is the regular expression that matches MediaSessionService logging the app whose media
+	// session receives media buttons, which is the app currently playing media.
+	// e.g. "Media button session is changed to com.example.music/MusicService (userId=0)"
+	mediaSessionRE = regexp.MustCompile(`(?i)media button session is changed to\s+(?P<package>[a-zA-Z]\w*(?:\.\w+)+)`)
+
+	// refreshRateRE is the regular expression that matches SurfaceFlinger logging a display refresh rate switch.
+	// e.g. "Switching refresh rate to 120Hz", "setActiveMode: refreshRate=90.00" or "refresh rate changed: 60 fps"
+	refreshRateRE = regexp.MustCompile(`(?i)refresh\s*rate(?:\s+changed)?(?:\s+to\s*|\s*[=:]\s*)(?P<rate>\d+(?:\.\d+)?)`)
 )
 
+// userActivityEvents maps the user activity event types logged by PowerManagerService to their names.
+// Defined as the USER_ACTIVITY_EVENT_* constants in frameworks/base/core/java/android/os/PowerManager.java.
+var userActivityEvents = map[string]string{
+	"0": "other",
+	"1": "button",
+	"2": "touch",
+	"3": "accessibility",
+	"4": "attention",
+}
+
 const (
 	// strictModePre matches the prefix of the first line of a StrictMode policy violation event.
 	strictModePre = "StrictMode policy violation;"
@@ -81,6 +169,9 @@ const (
 	// focusedActivityEvent is the string for matching focused activity events in the bug report.
 	focusedActivityEvent = "am_focused_activity"
 
+	// destroyActivityEvent is the string for matching activity destroyed events in the bug report.
+	destroyActivityEvent = "am_destroy_activity"
+
 	// lowMemoryANRGroup is the group name for low memory and application not responding events.
 	lowMemoryANRGroup = "AM Low Memory / ANR"
 
@@ -90,6 +181,9 @@ const (
 	// amWTFEvent is the string for matching am_wtf events in the bug report.
 	amWTFEvent = "am_wtf"
 
+	// wtf is the CSV description of am_wtf (What a Terrible Failure) events.
+	wtf = "WTF"
+
 	// crashes is the the CSV description of Crash events.
 	crashes = "Crashes"
 
@@ -114,9 +208,16 @@ type Log struct {
 // LogsData contains the CSV generated from the system and event logs and the start times of the logs.
 type LogsData struct {
 	// Logs is a map from section name to Log data.
-	Logs     map[string]*Log
-	Warnings []string
-	Errs     []error
+	Logs map[string]*Log
+	// AndroidSDK is the SDK version of the device the bugreport was taken on, or zero if unknown.
+	AndroidSDK int
+	// BuildFingerprint is the build fingerprint found in the bugreport header, or empty if unknown.
+	BuildFingerprint string
+	// BaseTimestampMs is the time of the first event, which event times are relative to
+	// when the RelativeTimestamps option is set. It is zero otherwise.
+	BaseTimestampMs int64
+	Warnings        []string
+	Errs            []error
 }
 
 // String returns a string representation of the LogsData.
@@ -162,6 +263,10 @@ type parser struct {
 	// partialEvent stores the existing state of a partially parsed event.
 	// e.g. a crash event occurs over several lines and can't be outputted until all parts are found.
 	partialEvent csv.Entry
+
+	// vpnPackage stores the package of the app that established the current VPN tunnel, if any.
+	// The log line for a tunnel being torn down doesn't include the package.
+	vpnPackage string
 }
 
 // newParser creates a parser for the given bugreport.
@@ -219,9 +324,38 @@ func (p *parser) fullTimestamp(month, day, partialTimestamp, remainder string) (
 	return bugreportutils.TimeStampToMs(fmt.Sprintf("%d-%s-%s %s", year, month, day, partialTimestamp), remainder, p.loc)
 }
 
+// Options configures how the logs in a bugreport are parsed.
+type Options struct {
+	// DedupWindow is the maximum difference between the timestamps of the same event reported in
+	// different log sections for the reports to be treated as duplicates. Only the first report is kept.
+	// Deduplication is disabled if this is negative.
+	DedupWindow time.Duration
+	// SortByStart stable sorts the events in each log section by start time. By default, events
+	// are output in the order they were logged, which isn't always time order.
+	SortByStart bool
+	// RelativeTimestamps outputs event times as milliseconds since the first event of any section,
+	// rather than since the Unix epoch. The first event's time is returned in BaseTimestampMs.
+	RelativeTimestamps bool
+	// TimestampFormat is the format event times are written in. By default, times are written in ms,
+	// as expected by the Historian frontend.
+	TimestampFormat csv.TimestampFormat
+}
+
+// DefaultOptions returns the options used by Parse.
+func DefaultOptions() Options {
+	return Options{
+		DedupWindow: time.Second,
+	}
+}
+
 // Parse writes a CSV entry for each line matching activity manager proc start and died, ANR and low memory events.
 // Package info is used to match crash events to UIDs. Errors encountered during parsing will be collected into an errors slice and will continue parsing remaining events.
 func Parse(pkgs []*usagepb.PackageInfo, f string) LogsData {
+	return ParseWithOptions(pkgs, f, DefaultOptions())
+}
+
+// ParseWithOptions is the same as Parse, but uses the given options rather than the defaults.
+func ParseWithOptions(pkgs []*usagepb.PackageInfo, f string, opts Options) LogsData {
 	p, warnings, err := newParser(f)
 	res := LogsData{Warnings: warnings, Logs: make(map[string]*Log)}
 	if err != nil {
@@ -232,6 +366,21 @@ func Parse(pkgs []*usagepb.PackageInfo, f string) LogsData {
 	// Pointer to the log data to modify. Will be stored in the Logs map.
 	var log *Log
 	for _, line := range strings.Split(f, "\n") {
+		// The build information is optional, so a bugreport missing it is still parsed.
+		// The prefix checks avoid running the regular expressions on every line.
+		if res.BuildFingerprint == "" && strings.HasPrefix(line, "Build fingerprint:") {
+			if m, result := historianutils.SubexpNames(bugreportutils.BuildFingerprintRE, line); m {
+				res.BuildFingerprint = result["build"]
+			}
+			continue
+		}
+		if res.AndroidSDK == 0 && strings.HasPrefix(line, "[ro.build.version.sdk]") {
+			if m, result := historianutils.SubexpNames(bugreportutils.SdkVersionRE, line); m {
+				// The regular expression ensures this is a number.
+				res.AndroidSDK, _ = strconv.Atoi(result["sdkVersion"])
+			}
+			continue
+		}
 		// We don't want to falsely match log lines that contain text matching the BugReportSectionRE.
 		// Even if we're not interested in these events, matching it as an unknown section heading
 		// leads to log lines being skipped.
@@ -243,15 +392,8 @@ func Parse(pkgs []*usagepb.PackageInfo, f string) LogsData {
 				log.CSV = appendCSVs(log.CSV, p.outputCSV(lastTimestamp))
 				log = nil
 			}
-			section := ""
-			switch {
-			case strings.HasPrefix(s, EventLogSection):
-				section = EventLogSection
-			case strings.HasPrefix(s, SystemLogSection):
-				section = SystemLogSection
-			case strings.HasPrefix(s, LastLogcatSection):
-				section = LastLogcatSection
-			default:
+			section := logSection(s)
+			if section == "" {
 				continue // Not a log section we're interested in.
 			}
 			// Only output a CSV header if it's the first time we're seeing a section.
@@ -301,20 +443,73 @@ func Parse(pkgs []*usagepb.PackageInfo, f string) LogsData {
 		if warning != "" {
 			res.Warnings = append(res.Warnings, warning)
 		}
+		p.runLineHandlers(timestamp, line)
 	}
 	// Reached the end of the logs. Output any pending events.
 	if log != nil {
 		log.CSV = appendCSVs(log.CSV, p.outputCSV(lastTimestamp))
 	}
+	if opts.DedupWindow >= 0 {
+		res.Errs = append(res.Errs, dedupSections(res.Logs, opts.DedupWindow)...)
+	}
+	if opts.SortByStart {
+		res.Errs = append(res.Errs, sortSections(res.Logs)...)
+	}
+	if opts.RelativeTimestamps {
+		var errs []error
+		res.BaseTimestampMs, errs = relativeSections(res.Logs)
+		res.Errs = append(res.Errs, errs...)
+	}
+	if opts.TimestampFormat != csv.EpochMillis {
+		res.Errs = append(res.Errs, formatSections(res.Logs, opts.TimestampFormat)...)
+	}
 	return res
 }
 
+// ImportanceBucket classifies a process by its oom_adj value, as logged when the process is killed.
+// The thresholds are the *_ADJ constants in frameworks/base/services/core/java/com/android/server/am/ProcessList.java.
+func ImportanceBucket(adj int) string {
+	switch {
+	case adj < 0:
+		// Persistent and native processes.
+		return "system"
+	case adj < 200:
+		// Foreground and visible apps.
+		return "foreground"
+	case adj < 500:
+		// Perceptible, backup and heavy weight apps.
+		return "perceptible"
+	case adj < 900:
+		// Services, the home app and the previous app.
+		return "service"
+	default:
+		return "cached"
+	}
+}
+
+// logSection returns the log section for the given bugreport section heading, or an empty string if
+// it isn't a log section that is parsed.
+func logSection(heading string) string {
+	for _, section := range []string{EventLogSection, SystemLogSection, LastLogcatSection} {
+		if strings.HasPrefix(heading, section) {
+			return section
+		}
+	}
+	return ""
+}
+
 // msToTime converts milliseconds since Unix Epoch to a time.Time object.
 func msToTime(ms int64) time.Time {
 	return time.Unix(0, ms*int64(time.Millisecond))
 }
 
 func (p *parser) outputCSV(curMs int64) string {
+	p.flushEvents(curMs)
+	return p.buf.String()
+}
+
+// flushEvents prints any pending events, ending active events at the given time.
+func (p *parser) flushEvents(curMs int64) {
 	// Output any partially parsed event if it's valid.
 	p.printPartial()
 
@@ -324,7 +519,6 @@ func (p *parser) outputCSV(curMs int64) string {
 	// End other active events at the last seen timestamp. Setting the end time to before the start
 	// time will cause the JS to explode unless it's handled specially as is the case for amProc.
 	p.csvState.PrintAllReset(curMs)
-	return p.buf.String()
 }
 
 func (p *parser) resetCSVState(outputHeader bool) {
@@ -429,6 +623,37 @@ func (p *parser) parseEvent(pkgs []*usagepb.PackageInfo, timestamp int64, event,
 			return "", nil
 		}
 	case "ActivityManager":
+		if m, result := historianutils.SubexpNames(displayedRE, details); m {
+			ms, err := historianutils.ParseDurationWithDays(result["latency"])
+			if err != nil {
+				return "", fmt.Errorf("could not parse launch latency %q: %v", result["latency"], err)
+			}
+			pkg := strings.SplitN(result["component"], "/", 2)[0]
+			uid, err := procToUID(pkg, pkgs)
+			p.csvState.PrintInstantEvent(csv.Entry{
+				Desc:  "App Launch",
+				Start: timestamp,
+				Type:  "service",
+				Value: strconv.FormatInt(ms, 10),
+				Opt:   uid,
+			})
+			return "", err
+		}
+		if m, result := historianutils.SubexpNames(forceStopRE, details); m {
+			uid, err := procToUID(result["package"], pkgs)
+			if uid == "" && result["appid"] != "" {
+				// The app ID is the UID of the package for the system user.
+				uid, err = result["appid"], nil
+			}
+			p.csvState.PrintInstantEvent(csv.Entry{
+				Desc:  "Force Stop",
+				Start: timestamp,
+				Type:  "service",
+				Value: result["package"],
+				Opt:   uid,
+			})
+			return "", err
+		}
 		// Detect ANR CPU usage reporting
 		if strings.Contains(details, "ANR in") {
 			// Extract app name from "ANR in <package>"
@@ -457,11 +682,17 @@ func (p *parser) parseEvent(pkgs []*usagepb.PackageInfo, timestamp int64, event,
 		}
 		// Detect process killing due to low memory
 		if strings.Contains(details, "Killing") && strings.Contains(details, "adj") {
+			value := details
+			if m, result := historianutils.SubexpNames(killAdjRE, details); m {
+				// The regular expression ensures this is a number.
+				adj, _ := strconv.Atoi(result["adj"])
+				value = fmt.Sprintf("%s (importance: %s)", details, ImportanceBucket(adj))
+			}
 			p.csvState.PrintInstantEvent(csv.Entry{
 				Desc:  "Process Killed (Low Memory)",
 				Start: timestamp,
 				Type:  "service",
-				Value: details,
+				Value: value,
 			})
 			return "", nil
 		}
@@ -511,6 +742,26 @@ func (p *parser) parseEvent(pkgs []*usagepb.PackageInfo, timestamp int64, event,
 			})
 		}
 		return "", nil
+	case "BluetoothLeAdvertiser":
+		desc := ""
+		switch {
+		case strings.Contains(details, "startAdvertising"):
+			desc = "BLE Advertising Started"
+		case strings.Contains(details, "stopAdvertising"):
+			desc = "BLE Advertising Stopped"
+		default:
+			p.printTagEvent(timestamp, event, details)
+			return "", nil
+		}
+		appName, uid := p.pidInfo(pid)
+		p.csvState.PrintInstantEvent(csv.Entry{
+			Desc:  desc,
+			Start: timestamp,
+			Type:  "service",
+			Value: fmt.Sprintf("%s (PID: %s)", appName, pid),
+			Opt:   uid,
+		})
+		return "", nil
 	case "AndroidRuntime":
 		if m, result := historianutils.SubexpNames(crashStartRE, details); m {
 			// Don't print out a crash event until we have the process details of what crashed.
@@ -554,11 +805,228 @@ func (p *parser) parseEvent(pkgs []*usagepb.PackageInfo, timestamp int64, event,
 			})
 			return "", nil
 		}
+	case "ConnectivityService":
+		if m, result := historianutils.SubexpNames(networkLostRE, details); m {
+			p.csvState.PrintInstantEvent(csv.Entry{
+				Desc:  "Network Down",
+				Start: timestamp,
+				Type:  "service",
+				Value: result["transport"],
+			})
+			return "", nil
+		}
+		if m, result := historianutils.SubexpNames(networkInterfaceRE, details); m {
+			desc := "Network Up"
+			if strings.EqualFold(result["state"], "down") {
+				desc = "Network Down"
+			}
+			p.csvState.PrintInstantEvent(csv.Entry{
+				Desc:  desc,
+				Start: timestamp,
+				Type:  "service",
+				Value: result["iface"],
+			})
+			return "", nil
+		}
+		p.printTagEvent(timestamp, event, details)
+		return "", nil
+	case "LocationManagerService":
+		if m, result := historianutils.SubexpNames(locationRequestRE, details); m {
+			value := result["package"]
+			if m, r := historianutils.SubexpNames(locationIntervalRE, details); m {
+				value = fmt.Sprintf("%s (interval: %s)", value, r["interval"])
+			}
+			uid, err := procToUID(result["package"], pkgs)
+			p.csvState.PrintInstantEvent(csv.Entry{
+				Desc:  "Location Request",
+				Start: timestamp,
+				Type:  "service",
+				Value: value,
+				Opt:   uid,
+			})
+			return "", err
+		}
+		p.printTagEvent(timestamp, event, details)
+		return "", nil
+	case "ThermalManagerService", "ShutdownThread":
+		if thermalShutdownRE.MatchString(details) {
+			p.csvState.PrintInstantEvent(csv.Entry{
+				Desc:  "Thermal Shutdown",
+				Start: timestamp,
+				Type:  "service",
+				Value: details,
+			})
+			return "", nil
+		}
+		p.printTagEvent(timestamp, event, details)
+		return "", nil
+	case "init":
+		if bootCompletedRE.MatchString(details) {
+			p.csvState.PrintInstantEvent(csv.Entry{
+				Desc:  "Boot Completed",
+				Start: timestamp,
+				Type:  "service",
+				Value: details,
+			})
+			return "", nil
+		}
+		p.printTagEvent(timestamp, event, details)
+		return "", nil
+	case "RIL", "RILJ", "RILC":
+		if modemResetRE.MatchString(details) {
+			p.csvState.PrintInstantEvent(csv.Entry{
+				Desc:  "Modem Reset",
+				Start: timestamp,
+				Type:  "service",
+				Value: details,
+			})
+			return "", nil
+		}
+		p.printTagEvent(timestamp, event, details)
+		return "", nil
+	case "DeviceIdleController":
+		if m, result := historianutils.SubexpNames(dozeWhitelistRE, details); m {
+			uid, err := procToUID(result["package"], pkgs)
+			p.csvState.PrintInstantEvent(csv.Entry{
+				Desc:  "Doze Whitelist",
+				Start: timestamp,
+				Type:  "service",
+				Value: result["package"],
+				Opt:   uid,
+			})
+			return "", err
+		}
+		p.printTagEvent(timestamp, event, details)
+		return "", nil
+	case "NotificationService":
+		if m, result := historianutils.SubexpNames(enqueueNotificationRE, details); m {
+			uid, err := procToUID(result["package"], pkgs)
+			p.csvState.PrintInstantEvent(csv.Entry{
+				Desc:  "Notification Posted",
+				Start: timestamp,
+				Type:  "service",
+				Value: result["package"],
+				Opt:   uid,
+			})
+			return "", err
+		}
+		p.printTagEvent(timestamp, event, details)
+		return "", nil
+	case "PowerManagerService":
+		if m, result := historianutils.SubexpNames(userActivityRE, details); m {
+			value, ok := userActivityEvents[result["event"]]
+			if !ok {
+				value = "event " + result["event"]
+			}
+			p.csvState.PrintInstantEvent(csv.Entry{
+				Desc:  "User Activity",
+				Start: timestamp,
+				Type:  "service",
+				Value: value,
+				Opt:   result["uid"],
+			})
+			return "", nil
+		}
+		p.printTagEvent(timestamp, event, details)
+		return "", nil
+	case "Vpn":
+		if m, result := historianutils.SubexpNames(vpnEstablishedRE, details); m {
+			p.vpnPackage = result["package"]
+			uid, err := procToUID(p.vpnPackage, pkgs)
+			p.csvState.PrintInstantEvent(csv.Entry{
+				Desc:  "VPN Up",
+				Start: timestamp,
+				Type:  "service",
+				Value: p.vpnPackage,
+				Opt:   uid,
+			})
+			return "", err
+		}
+		if vpnDisconnectedRE.MatchString(details) {
+			var uid string
+			var err error
+			if p.vpnPackage != "" {
+				uid, err = procToUID(p.vpnPackage, pkgs)
+			}
+			p.csvState.PrintInstantEvent(csv.Entry{
+				Desc:  "VPN Down",
+				Start: timestamp,
+				Type:  "service",
+				Value: p.vpnPackage,
+				Opt:   uid,
+			})
+			p.vpnPackage = ""
+			return "", err
+		}
+		p.printTagEvent(timestamp, event, details)
+		return "", nil
+	case "WifiService":
+		if m, result := historianutils.SubexpNames(wifiScanRequestRE, details); m {
+			uid, value := result["uid"], result["package"]
+			var err error
+			if uid == "" && value != "" {
+				uid, err = procToUID(value, pkgs)
+			}
+			if value == "" {
+				value = uid
+			}
+			p.csvState.PrintInstantEvent(csv.Entry{
+				Desc:  "WiFi Scan",
+				Start: timestamp,
+				Type:  "service",
+				Value: value,
+				Opt:   uid,
+			})
+			return "", err
+		}
+		p.printTagEvent(timestamp, event, details)
+		return "", nil
+	case "MediaSessionService":
+		if m, result := historianutils.SubexpNames(mediaSessionRE, details); m {
+			uid, err := procToUID(result["package"], pkgs)
+			p.csvState.PrintInstantEvent(csv.Entry{
+				Desc:  "Media Session",
+				Start: timestamp,
+				Type:  "service",
+				Value: result["package"],
+				Opt:   uid,
+			})
+			return "", err
+		}
+		p.printTagEvent(timestamp, event, details)
+		return "", nil
+	case "SurfaceFlinger":
+		if m, result := historianutils.SubexpNames(refreshRateRE, details); m {
+			rate := result["rate"]
+			// Rates are sometimes logged with a fraction, e.g. 120.00.
+			if r, err := strconv.ParseFloat(rate, 64); err == nil {
+				rate = strconv.FormatFloat(r, 'f', -1, 64)
+			}
+			p.csvState.PrintInstantEvent(csv.Entry{
+				Desc:  "Refresh Rate",
+				Start: timestamp,
+				Type:  "service",
+				Value: rate + "Hz",
+			})
+			return "", nil
+		}
+		if m, result := historianutils.SubexpNames(choreographerRE, details); m {
+			p.csvState.PrintInstantEvent(csv.Entry{
+				Desc:  "Jank",
+				Start: timestamp,
+				Type:  "service",
+				Value: result["numFrames"],
+			})
+			return "", nil
+		}
+		p.printTagEvent(timestamp, event, details)
+		return "", nil
 	case "Choreographer":
 		if m, result := historianutils.SubexpNames(choreographerRE, details); m {
 			_, uid := p.pidInfo(pid)
+			// Reported under the same metric as the frames skipped by SurfaceFlinger.
 			p.csvState.PrintInstantEvent(csv.Entry{
-				Desc:  "Choreographer (skipped frames)",
+				Desc:  "Jank",
 				Start: timestamp,
 				Type:  "service",
 				Value: result["numFrames"],
@@ -572,15 +1040,26 @@ func (p *parser) parseEvent(pkgs []*usagepb.PackageInfo, timestamp int64, event,
 		if strings.HasPrefix(details, "[") { // Encountered start of am_wtf event.
 			// Possible we have a stored existing am_wtf event. Print it out if it exists.
 			p.printPartial()
+			// Expected format: User,PID,Process Name,Flags,Tag,Message
+			// The message may contain commas, and may continue over several lines.
+			v := strings.Trim(details, "[]")
+			parts := strings.SplitN(v, ",", 6)
+			var uid string
+			var err error
+			if len(parts) == 6 {
+				v = fmt.Sprintf("%s: %s", parts[4], parts[5])
+				uid, err = procToUID(parts[2], pkgs)
+			}
 			// Sometimes the event is multi-line, so save the event for printing later.
 			p.partialEvent = csv.Entry{
-				Desc:  event,
+				Desc:  wtf,
 				Start: timestamp,
 				Type:  "service",
-				Value: strings.Trim(details, "[]"),
+				Value: v,
+				Opt:   uid,
 			}
-			return "", nil
-		} else if p.partialEvent.Desc != amWTFEvent { // No saved am_wtf event, and it's not the start of an am_wtf event.
+			return "", err
+		} else if p.partialEvent.Desc != wtf { // No saved am_wtf event, and it's not the start of an am_wtf event.
 			return "", fmt.Errorf("am_wtf event with non expected format: %s", amWTFEvent)
 		}
 		p.partialEvent.Value += "\n" + strings.Trim(details, "]") // Continuation of existing event.
@@ -617,6 +1096,29 @@ func (p *parser) parseEvent(pkgs []*usagepb.PackageInfo, timestamp int64, event,
 			}
 		}
 		return "", nil
+	case destroyActivityEvent:
+		details = strings.Trim(details, "[]")
+		// Expected format: User,Token,Task ID,Component Name,Reason
+		// e.g. [0,219367232,1134,com.google.android.gm/.ConversationListActivityGmail,finish-imm]
+		parts := strings.Split(details, ",")
+		warning, err := verifyLen(destroyActivityEvent, parts, 5)
+		if err != nil {
+			return warning, err
+		}
+		component := parts[3]
+		pkgName := component
+		if idx := strings.Index(component, "/"); idx > 0 {
+			pkgName = component[:idx]
+		}
+		uid, err := procToUID(pkgName, pkgs)
+		p.csvState.PrintInstantEvent(csv.Entry{
+			Desc:  "Activity Destroyed",
+			Start: timestamp,
+			Type:  "service",
+			Value: component,
+			Opt:   uid,
+		})
+		return warning, err
 	case anrEvent:
 		details = strings.Trim(details, "[]")
 		return p.parseANR(pkgs, timestamp, details)
@@ -640,17 +1142,23 @@ func (p *parser) parseEvent(pkgs []*usagepb.PackageInfo, timestamp int64, event,
 		})
 		return warning, err
 	default:
-		details = strings.Trim(details, "[]")
-		p.csvState.PrintInstantEvent(csv.Entry{
-			Desc:  event,
-			Start: timestamp,
-			Type:  "service",
-			Value: details,
-		})
+		p.printTagEvent(timestamp, event, details)
 	}
 	return "", nil
 }
 
+// printTagEvent outputs a line that isn't recognized as a specific event as an instant event named
+// after its tag. Cases for specific tags fall back to this for the lines they don't recognize, so
+// adding a case for a tag doesn't drop its other lines.
+func (p *parser) printTagEvent(timestamp int64, event, details string) {
+	p.csvState.PrintInstantEvent(csv.Entry{
+		Desc:  event,
+		Start: timestamp,
+		Type:  "service",
+		Value: strings.Trim(details, "[]"),
+	})
+}
+
 // pidInfo converts the PID to the corresponding app name/s and UID.
 // If there is no available info for the PID, the app name will be unknown,
 // and an empty string returned for the UID.
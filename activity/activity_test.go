@@ -531,11 +531,11 @@ func TestParse(t *testing.T) {
 					EventLogSection: &Log{
 						CSV: strings.Join([]string{
 							csv.FileHeader,
-							`am_wtf,service,1446732919609,1446732919609,"0,2475,com.google.android.gms.persistent,-1194836283,StrictMode,Stack is too large: numViolations=5 policy=#1600007 front=android.os.StrictMode$StrictModeDiskReadViolation: policy=23068679 violation=2` + "\n" +
+							`WTF,service,1446732919609,1446732919609,"StrictMode: Stack is too large: numViolations=5 policy=#1600007 front=android.os.StrictMode$StrictModeDiskReadViolation: policy=23068679 violation=2` + "\n" +
 								`at android.os.StrictMode$AndroidBlockGuardPolicy.onReadFromDisk(StrictMode.java:1293)` + "\n" +
 								`at libcore.io.BlockGuardOs.read(BlockGuardOs.java:230)` + "\n" +
 								`at libcore.io.IoBridge.read(IoBri",`,
-							`am_wtf,service,1446732921609,1446732921609,"0,4723,system_server,-1,ActivityManager,Sending non-protected broadcast android.net.wifi.DHCP_RENEW from system",`,
+							`WTF,service,1446732921609,1446732921609,ActivityManager: Sending non-protected broadcast android.net.wifi.DHCP_RENEW from system,`,
 						}, "\n"),
 						StartMs: 1446732919609,
 					},
@@ -618,7 +618,7 @@ func TestParse(t *testing.T) {
 					SystemLogSection: &Log{
 						CSV: strings.Join([]string{
 							csv.FileHeader,
-							`Choreographer (skipped frames),service,1456789514575,1456789514575,60,1000`,
+							`Jank,service,1456789514575,1456789514575,60,1000`,
 						}, "\n"),
 						StartMs: 1456789514575,
 					},
@@ -908,6 +908,59 @@ func TestParse(t *testing.T) {
 				},
 			},
 		},
+		{
+			desc: "am_destroy_activity event",
+			input: []string{
+				`========================================================`,
+				`== dumpstate: 2015-09-27 21:04:31`,
+				`========================================================`,
+				`...`,
+				`------ EVENT LOG (logcat -b events -v threadtime -d *:v) ------`,
+				`09-27 20:44:59.609   808   822 I am_destroy_activity: [0,219367232,1134,com.google.android.gm/.ConversationListActivityGmail,finish-imm]`,
+				`...`,
+				`[persist.sys.timezone]: [America/Los_Angeles]`,
+			},
+			pkgs: []*usagepb.PackageInfo{
+				{PkgName: proto.String("com.google.android.gm"), Uid: proto.Int32(10023)},
+			},
+			wantLogsData: LogsData{
+				Logs: map[string]*Log{
+					EventLogSection: &Log{
+						CSV: strings.Join([]string{
+							csv.FileHeader,
+							`Activity Destroyed,service,1443411899609,1443411899609,com.google.android.gm/.ConversationListActivityGmail,10023`,
+						}, "\n"),
+						StartMs: 1443411899609,
+					},
+				},
+			},
+		},
+		{
+			desc: "am_wtf event attributed to app",
+			input: []string{
+				`========================================================`,
+				`== dumpstate: 2015-11-05 06:30:29`,
+				`========================================================`,
+				`------ EVENT LOG (logcat -b events -v threadtime -d *:v) ------`,
+				`11-05 06:15:21.609 4723  5868 I am_wtf  : [0,4723,com.google.example,-1,ExampleTag,Unexpected state, recovering]`,
+				`...`,
+				`[persist.sys.timezone]: [America/Los_Angeles]`,
+			},
+			pkgs: []*usagepb.PackageInfo{
+				{PkgName: proto.String("com.google.example"), Uid: proto.Int32(10114)},
+			},
+			wantLogsData: LogsData{
+				Logs: map[string]*Log{
+					EventLogSection: &Log{
+						CSV: strings.Join([]string{
+							csv.FileHeader,
+							`WTF,service,1446732921609,1446732921609,"ExampleTag: Unexpected state, recovering",10114`,
+						}, "\n"),
+						StartMs: 1446732921609,
+					},
+				},
+			},
+		},
 	}
 	for _, test := range tests {
 		got := Parse(test.pkgs, strings.Join(test.input, "\n"))
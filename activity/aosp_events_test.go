@@ -15,8 +15,11 @@
 package activity
 
 import (
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/google/battery-historian/csv"
 )
 
 // bugreportHeader returns the standard bugreport header with timezone info.
@@ -88,6 +91,198 @@ func TestAOSPEnhancedEvents(t *testing.T) {
 			wantDesc: "System Watchdog",
 			wantVal:  "WATCHDOG KILLING SYSTEM PROCESS",
 		},
+		{
+			desc: "Thermal shutdown",
+			logLines: []string{
+				"09-27 20:50:00.000  1963  1976 W ThermalManagerService: Thermal shutdown triggered: skin temperature 68C",
+			},
+			wantDesc: "Thermal Shutdown",
+			wantVal:  "skin temperature 68C",
+		},
+		{
+			desc: "Thermal reboot reason",
+			logLines: []string{
+				"09-27 20:51:00.000  1963  1976 I ShutdownThread: reboot reason: thermal",
+			},
+			wantDesc: "Thermal Shutdown",
+			wantVal:  "reboot reason: thermal",
+		},
+		{
+			desc: "DeviceIdleController doze whitelist",
+			logLines: []string{
+				"09-27 20:46:00.000  1963  2104 I DeviceIdleController: Doze: com.google.android.apps.fitness whitelisted",
+			},
+			wantDesc: "Doze Whitelist",
+			wantVal:  "com.google.android.apps.fitness",
+		},
+		{
+			desc: "DeviceIdleController user whitelist addition",
+			logLines: []string{
+				"09-27 20:46:00.000  1963  2104 I DeviceIdleController: Adding com.example.app to user whitelist",
+			},
+			wantDesc: "Doze Whitelist",
+			wantVal:  "com.example.app",
+		},
+		{
+			desc: "ActivityManager force stop",
+			logLines: []string{
+				"09-27 20:47:00.000  1963  2104 I ActivityManager: Force stopping com.example.app appid=10055 user=0: from pid 24840",
+			},
+			wantDesc: "Force Stop",
+			wantVal:  "com.example.app",
+		},
+		{
+			desc: "ConnectivityService network lost",
+			logLines: []string{
+				"09-27 20:48:00.000  1963  2150 D ConnectivityService: NetworkAgentInfo [WIFI () - 100] lost",
+			},
+			wantDesc: "Network Down",
+			wantVal:  "WIFI",
+		},
+		{
+			desc: "ConnectivityService interface up",
+			logLines: []string{
+				"09-27 20:48:05.000  1963  2150 D ConnectivityService: interface rmnet_data0 is up",
+			},
+			wantDesc: "Network Up",
+			wantVal:  "rmnet_data0",
+		},
+		{
+			desc: "LocationManagerService location request",
+			logLines: []string{
+				"09-27 20:49:00.000  1963  2200 I LocationManagerService: requestLocationUpdates: provider=gps interval=1000 by com.example.maps",
+			},
+			wantDesc: "Location Request",
+			wantVal:  "com.example.maps (interval: 1000)",
+		},
+		{
+			desc: "LocationManagerService location request without interval",
+			logLines: []string{
+				"09-27 20:49:00.000  1963  2200 I LocationManagerService: requestLocationUpdates by com.example.maps",
+			},
+			wantDesc: "Location Request",
+			wantVal:  "com.example.maps",
+		},
+		{
+			desc: "RIL radio unavailable",
+			logLines: []string{
+				"09-27 20:50:00.000  2345  2400 D RILJ    : [3456]< RADIO_POWER RADIO_UNAVAILABLE [PHONE0]",
+			},
+			wantDesc: "Modem Reset",
+			wantVal:  "RADIO_UNAVAILABLE",
+		},
+		{
+			desc: "Modem reset notice",
+			logLines: []string{
+				"09-27 20:50:01.000  2345  2400 E RIL     : Modem reset detected, reason: SSR",
+			},
+			wantDesc: "Modem Reset",
+			wantVal:  "Modem reset detected",
+		},
+		{
+			desc: "ActivityManager displayed launch latency",
+			logLines: []string{
+				"09-27 20:52:00.000  1963  2104 I ActivityManager: Displayed com.example.app/.MainActivity: +1s234ms",
+			},
+			wantDesc: "App Launch",
+			wantVal:  "1234",
+		},
+		{
+			desc: "init boot completed",
+			logLines: []string{
+				"09-27 20:53:00.000     1     1 I init    : processing action (sys.boot_completed=1) from (/system/etc/init/bootstat.rc:75)",
+			},
+			wantDesc: "Boot Completed",
+			wantVal:  "sys.boot_completed=1",
+		},
+		{
+			desc: "BLE advertising started",
+			logLines: []string{
+				"09-27 20:54:00.000  24840 24851 D BluetoothLeAdvertiser: startAdvertisingSet() - reg_id=3, status=0",
+			},
+			wantDesc: "BLE Advertising Started",
+			wantVal:  "PID: 24840",
+		},
+		{
+			desc: "BLE advertising stopped",
+			logLines: []string{
+				"09-27 20:54:10.000  24840 24851 D BluetoothLeAdvertiser: stopAdvertisingSet() - advertiser_id=3",
+			},
+			wantDesc: "BLE Advertising Stopped",
+			wantVal:  "PID: 24840",
+		},
+		{
+			desc: "notification posted",
+			logLines: []string{
+				"09-27 20:55:00.000  1234  1500 I NotificationService: enqueueNotificationInternal: pkg=com.example.app id=1 notification=Notification(channel=chat pri=0)",
+			},
+			wantDesc: "Notification Posted",
+			wantVal:  "com.example.app",
+		},
+		{
+			desc: "user activity",
+			logLines: []string{
+				"09-27 20:56:00.000  1234  1500 D PowerManagerService: userActivityNoUpdateLocked: eventTime=123456, event=2, flags=0x0, uid=1000",
+			},
+			wantDesc: "User Activity",
+			wantVal:  "touch",
+		},
+		{
+			desc: "VPN established",
+			logLines: []string{
+				"09-27 20:57:00.000  1234  1500 I Vpn     : Established by com.example.vpn on tun0",
+			},
+			wantDesc: "VPN Up",
+			wantVal:  "com.example.vpn",
+		},
+		{
+			desc: "WiFi scan request",
+			logLines: []string{
+				"09-27 20:59:00.000  1234  1500 I WifiService: startScan uid=10061 packageName=com.example.app",
+			},
+			wantDesc: "WiFi Scan",
+			wantVal:  "com.example.app,10061",
+		},
+		{
+			desc: "Low memory kill of a cached process",
+			logLines: []string{
+				"09-27 20:47:30.000  1963  1976 I ActivityManager: Killing 30400:com.example.news/u0a210 (adj 906): empty #17",
+			},
+			wantDesc: "Process Killed (Low Memory)",
+			wantVal:  "(adj 906): empty #17 (importance: cached)",
+		},
+		{
+			desc: "media session changed",
+			logLines: []string{
+				"09-27 21:00:00.000  1234  1500 D MediaSessionService: Media button session is changed to com.example.music/MusicService (userId=0)",
+			},
+			wantDesc: "Media Session",
+			wantVal:  "com.example.music",
+		},
+		{
+			desc: "refresh rate switch",
+			logLines: []string{
+				"09-27 21:01:00.000   600   700 I SurfaceFlinger: Switching refresh rate to 120Hz",
+			},
+			wantDesc: "Refresh Rate",
+			wantVal:  "120Hz",
+		},
+		{
+			desc: "refresh rate with fraction",
+			logLines: []string{
+				"09-27 21:01:30.000   600   700 D SurfaceFlinger: setActiveMode: refreshRate=90.00",
+			},
+			wantDesc: "Refresh Rate",
+			wantVal:  ",90Hz,",
+		},
+		{
+			desc: "Thermal reboot by ShutdownThread",
+			logLines: []string{
+				"09-27 20:51:30.000  1963  1976 I ShutdownThread: Rebooting, reason: shutdown,thermal",
+			},
+			wantDesc: "Thermal Shutdown",
+			wantVal:  "Rebooting, reason: shutdown,thermal",
+		},
 	}
 
 	for _, test := range tests {
@@ -129,6 +324,246 @@ func TestAOSPEnhancedEvents(t *testing.T) {
 	}
 }
 
+// systemLogEvents returns the events parsed from the given system log lines.
+func systemLogEvents(t *testing.T, lines ...string) []Event {
+	t.Helper()
+	input := strings.Join(append([]string{
+		bugreportHeader(),
+		"------ SYSTEM LOG (logcat -v threadtime -d *:v) ------",
+		"--------- beginning of system",
+	}, lines...), "\n")
+	systemLog, ok := Parse(nil, input).Logs[SystemLogSection]
+	if !ok || systemLog == nil {
+		t.Fatalf("Parse() got no system log section")
+	}
+	events, err := systemLog.Events()
+	if err != nil {
+		t.Fatalf("Events() unexpected error: %v", err)
+	}
+	return events
+}
+
+// TestUnrelatedLinesIgnored tests that lines mentioning an event, but not logged by the service
+// that reports it, or not reporting the event itself, don't produce the event.
+func TestUnrelatedLinesIgnored(t *testing.T) {
+	tests := []struct {
+		desc       string
+		line       string
+		unwantDesc string
+	}{
+		{
+			desc:       "Thermal shutdown threshold",
+			line:       "09-27 20:50:00.000  1963  1976 I ThermalManagerService: thermal shutdown threshold set to 68C",
+			unwantDesc: "Thermal Shutdown",
+		},
+		{
+			desc:       "Thermal shutdown mentioned by another tag",
+			line:       "09-27 20:50:00.000  1963  1976 I ThermalService: Thermal shutdown triggered: skin temperature 68C",
+			unwantDesc: "Thermal Shutdown",
+		},
+		{
+			desc:       "Modem restart mentioned by an app",
+			line:       "09-27 20:50:01.000  5678  5678 I SettingsUI: showing modem restart option",
+			unwantDesc: "Modem Reset",
+		},
+		{
+			desc:       "Boot completed property read by an app",
+			line:       "09-27 20:53:00.000  5678  5678 D BootReceiver: sys.boot_completed=1, starting sync",
+			unwantDesc: "Boot Completed",
+		},
+		{
+			desc:       "Doze whitelist config file",
+			line:       "09-27 20:46:00.000  1963  2104 I DeviceIdleController: Reading config from /data/system/deviceidle.xml whitelist",
+			unwantDesc: "Doze Whitelist",
+		},
+		{
+			desc:       "Doze whitelist removal",
+			line:       "09-27 20:46:00.000  1963  2104 I DeviceIdleController: Removing com.example.app from whitelist",
+			unwantDesc: "Doze Whitelist",
+		},
+		{
+			desc:       "VPN establish failure",
+			line:       "09-27 20:57:00.000  1234  1500 E Vpn     : establish failed: java.lang.IllegalStateException",
+			unwantDesc: "VPN Up",
+		},
+	}
+	for _, test := range tests {
+		for _, e := range systemLogEvents(t, test.line) {
+			if e.Metric == test.unwantDesc {
+				t.Errorf("%v: Parse() got unexpected %q event: %v", test.desc, test.unwantDesc, e)
+			}
+		}
+	}
+}
+
+// TestParsedEventFields tests the fields of the events parsed from system log lines.
+func TestParsedEventFields(t *testing.T) {
+	tests := []struct {
+		desc     string
+		logLines []string
+		want     []Event
+	}{
+		{
+			desc: "VPN disconnected without a known tunnel",
+			logLines: []string{
+				"09-27 20:58:00.000  1234  1500 D Vpn     : setting state=DISCONNECTED, reason=agentDisconnect",
+			},
+			want: []Event{
+				{Metric: "VPN Down", Event: csv.Event{Type: "service", Start: 1443387480000, End: 1443387480000}},
+			},
+		},
+		{
+			desc: "WiFi scan request without package",
+			logLines: []string{
+				"09-27 20:59:30.000  1234  1500 I WifiService: startScan uid=10061",
+			},
+			want: []Event{
+				{Metric: "WiFi Scan", Event: csv.Event{Type: "service", Start: 1443387570000, End: 1443387570000, Value: "10061", Opt: "10061"}},
+			},
+		},
+		{
+			desc: "SurfaceFlinger skipped frames",
+			logLines: []string{
+				"09-27 20:51:00.000   512   512 I SurfaceFlinger: Skipped 60 frames!  The application may be doing too much work on its main thread.",
+			},
+			want: []Event{
+				{Metric: "Jank", Event: csv.Event{Type: "service", Start: 1443387060000, End: 1443387060000, Value: "60"}},
+			},
+		},
+		{
+			desc: "Choreographer skipped frames",
+			logLines: []string{
+				"09-27 20:51:00.000 24830 24830 I Choreographer: Skipped 60 frames!  The application may be doing too much work on its main thread.",
+			},
+			want: []Event{
+				{Metric: "Jank", Event: csv.Event{Type: "service", Start: 1443387060000, End: 1443387060000, Value: "60"}},
+			},
+		},
+		{
+			desc: "Unrecognized ConnectivityService line",
+			logLines: []string{
+				"09-27 21:00:00.000  1234  1500 D ConnectivityService: NetworkAgentInfo [WIFI () - 100] validation passed",
+			},
+			want: []Event{
+				{Metric: "ConnectivityService", Event: csv.Event{Type: "service", Start: 1443387600000, End: 1443387600000, Value: "NetworkAgentInfo [WIFI () - 100] validation passed"}},
+			},
+		},
+		{
+			desc: "Unrecognized PowerManagerService line",
+			logLines: []string{
+				"09-27 21:01:00.000  1234  1500 I PowerManagerService: Going to sleep due to screen timeout (uid 1000)...",
+			},
+			want: []Event{
+				{Metric: "PowerManagerService", Event: csv.Event{Type: "service", Start: 1443387660000, End: 1443387660000, Value: "Going to sleep due to screen timeout (uid 1000)..."}},
+			},
+		},
+		{
+			desc: "Unrecognized init line",
+			logLines: []string{
+				"09-27 21:02:00.000  1234  1500 I init: starting service 'foo'...",
+			},
+			want: []Event{
+				{Metric: "init", Event: csv.Event{Type: "service", Start: 1443387720000, End: 1443387720000, Value: "starting service 'foo'..."}},
+			},
+		},
+		{
+			desc: "Unrecognized WifiService line",
+			logLines: []string{
+				"09-27 21:03:00.000  1234  1500 I WifiService: setWifiEnabled: true",
+			},
+			want: []Event{
+				{Metric: "WifiService", Event: csv.Event{Type: "service", Start: 1443387780000, End: 1443387780000, Value: "setWifiEnabled: true"}},
+			},
+		},
+		{
+			desc: "Unrecognized ShutdownThread line",
+			logLines: []string{
+				"09-27 21:04:00.000  1234  1500 I ShutdownThread: Notifying thread to start shutdown longPressBehavior=1",
+			},
+			want: []Event{
+				{Metric: "ShutdownThread", Event: csv.Event{Type: "service", Start: 1443387840000, End: 1443387840000, Value: "Notifying thread to start shutdown longPressBehavior=1"}},
+			},
+		},
+		{
+			desc: "Unrecognized RILJ line",
+			logLines: []string{
+				"09-27 21:05:00.000  1234  1500 I RILJ: [3457]< SIGNAL_STRENGTH [PHONE0]",
+			},
+			want: []Event{
+				{Metric: "RILJ", Event: csv.Event{Type: "service", Start: 1443387900000, End: 1443387900000, Value: "3457]< SIGNAL_STRENGTH [PHONE0"}},
+			},
+		},
+		{
+			desc: "Unrecognized DeviceIdleController line",
+			logLines: []string{
+				"09-27 21:06:00.000  1234  1500 I DeviceIdleController: Moved from STATE_ACTIVE to STATE_INACTIVE.",
+			},
+			want: []Event{
+				{Metric: "DeviceIdleController", Event: csv.Event{Type: "service", Start: 1443387960000, End: 1443387960000, Value: "Moved from STATE_ACTIVE to STATE_INACTIVE."}},
+			},
+		},
+		{
+			desc: "Unrecognized LocationManagerService line",
+			logLines: []string{
+				"09-27 21:07:00.000  1234  1500 I LocationManagerService: removeUpdates: Receiver{5c2b2 listener}",
+			},
+			want: []Event{
+				{Metric: "LocationManagerService", Event: csv.Event{Type: "service", Start: 1443388020000, End: 1443388020000, Value: "removeUpdates: Receiver{5c2b2 listener}"}},
+			},
+		},
+		{
+			desc: "Unrecognized NotificationService line",
+			logLines: []string{
+				"09-27 21:08:00.000  1234  1500 I NotificationService: Cannot find enqueued record for key: 0|com.example.app|1|null|10061",
+			},
+			want: []Event{
+				{Metric: "NotificationService", Event: csv.Event{Type: "service", Start: 1443388080000, End: 1443388080000, Value: "Cannot find enqueued record for key: 0|com.example.app|1|null|10061"}},
+			},
+		},
+		{
+			desc: "Unrecognized Vpn line",
+			logLines: []string{
+				"09-27 21:09:00.000  1234  1500 I Vpn: setting state=CONNECTING, reason=establish",
+			},
+			want: []Event{
+				{Metric: "Vpn", Event: csv.Event{Type: "service", Start: 1443388140000, End: 1443388140000, Value: "setting state=CONNECTING, reason=establish"}},
+			},
+		},
+		{
+			desc: "Unrecognized MediaSessionService line",
+			logLines: []string{
+				"09-27 21:10:00.000  1234  1500 I MediaSessionService: Sending KeyEvent to com.example.music",
+			},
+			want: []Event{
+				{Metric: "MediaSessionService", Event: csv.Event{Type: "service", Start: 1443388200000, End: 1443388200000, Value: "Sending KeyEvent to com.example.music"}},
+			},
+		},
+		{
+			desc: "Unrecognized BluetoothLeAdvertiser line",
+			logLines: []string{
+				"09-27 21:11:00.000  1234  1500 I BluetoothLeAdvertiser: Advertise data too large",
+			},
+			want: []Event{
+				{Metric: "BluetoothLeAdvertiser", Event: csv.Event{Type: "service", Start: 1443388260000, End: 1443388260000, Value: "Advertise data too large"}},
+			},
+		},
+		{
+			desc: "Unrecognized SurfaceFlinger line",
+			logLines: []string{
+				"09-27 21:12:00.000  1234  1500 I SurfaceFlinger: Display 0 HWC layers:",
+			},
+			want: []Event{
+				{Metric: "SurfaceFlinger", Event: csv.Event{Type: "service", Start: 1443388320000, End: 1443388320000, Value: "Display 0 HWC layers:"}},
+			},
+		},
+	}
+	for _, test := range tests {
+		if got := systemLogEvents(t, test.logLines...); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: Parse() events = %v, want %v", test.desc, got, test.want)
+		}
+	}
+}
+
 // TestBluetoothScanStopTracking tests BLE scan stop event tracking.
 func TestBluetoothScanStopTracking(t *testing.T) {
 	input := strings.Join([]string{
@@ -155,3 +590,84 @@ func TestBluetoothScanStopTracking(t *testing.T) {
 		t.Error("Parse() CSV missing Bluetooth Scan Stopped event")
 	}
 }
+
+// TestParseBuildInfo tests that the SDK version and build fingerprint are read from the bugreport header.
+func TestParseBuildInfo(t *testing.T) {
+	input := strings.Join([]string{
+		"========================================================",
+		"== dumpstate: 2015-09-27 20:44:59",
+		"========================================================",
+		"",
+		"Build: AP2A.240805.005",
+		"Build fingerprint: 'google/husky/husky:14/AP2A.240805.005/12025142:user/release-keys'",
+		"",
+		"[ro.build.version.sdk]: [34]",
+		"[persist.sys.timezone]: [America/Los_Angeles]",
+		"------ SYSTEM LOG (logcat -v threadtime -d *:v) ------",
+		"09-27 20:44:00.000  1963  1976 W ActivityManager: WATCHDOG KILLING SYSTEM PROCESS",
+	}, "\n")
+
+	result := Parse(nil, input)
+	if result.AndroidSDK != 34 {
+		t.Errorf("Parse() AndroidSDK = %d, want 34", result.AndroidSDK)
+	}
+	if want := "google/husky/husky:14/AP2A.240805.005/12025142:user/release-keys"; result.BuildFingerprint != want {
+		t.Errorf("Parse() BuildFingerprint = %q, want %q", result.BuildFingerprint, want)
+	}
+
+	// The fingerprint is kept when there is no SDK line.
+	result = Parse(nil, strings.Replace(input, "[ro.build.version.sdk]: [34]\n", "", 1))
+	if result.AndroidSDK != 0 {
+		t.Errorf("Parse() without SDK line AndroidSDK = %d, want 0", result.AndroidSDK)
+	}
+	if want := "google/husky/husky:14/AP2A.240805.005/12025142:user/release-keys"; result.BuildFingerprint != want {
+		t.Errorf("Parse() without SDK line BuildFingerprint = %q, want %q", result.BuildFingerprint, want)
+	}
+}
+
+// TestVPNDownPackage tests that VPN Down events are attributed to the app that established the tunnel.
+func TestVPNDownPackage(t *testing.T) {
+	input := strings.Join([]string{
+		bugreportHeader(),
+		"------ SYSTEM LOG (logcat -v threadtime -d *:v) ------",
+		"--------- beginning of system",
+		"09-27 20:57:00.000  1234  1500 I Vpn     : Established by com.example.vpn on tun0",
+		"09-27 20:58:00.000  1234  1500 D Vpn     : setting state=DISCONNECTED, reason=agentDisconnect",
+	}, "\n")
+
+	result := Parse(nil, input)
+	systemLog, ok := result.Logs[SystemLogSection]
+	if !ok || systemLog == nil {
+		t.Fatal("Parse() got no system log section")
+	}
+	for _, want := range []string{"VPN Up,service", "VPN Down,service"} {
+		if !strings.Contains(systemLog.CSV, want+",") {
+			t.Errorf("Parse() CSV missing %q event:\n%s", want, systemLog.CSV)
+		}
+	}
+	if got := strings.Count(systemLog.CSV, ",com.example.vpn,"); got != 2 {
+		t.Errorf("Parse() CSV has %d events for com.example.vpn, want 2:\n%s", got, systemLog.CSV)
+	}
+}
+
+// TestImportanceBucket tests the classification of processes by their oom_adj value.
+func TestImportanceBucket(t *testing.T) {
+	tests := []struct {
+		adj  int
+		want string
+	}{
+		{-800, "system"},
+		{0, "foreground"},
+		{100, "foreground"},
+		{200, "perceptible"},
+		{500, "service"},
+		{700, "service"},
+		{900, "cached"},
+		{999, "cached"},
+	}
+	for _, test := range tests {
+		if got := ImportanceBucket(test.adj); got != test.want {
+			t.Errorf("ImportanceBucket(%d) = %q, want %q", test.adj, got, test.want)
+		}
+	}
+}
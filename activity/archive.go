@@ -0,0 +1,155 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package activity
+
+// archive.go supports parsing bugreports that are distributed as archives rather than a single flat file.
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	usagepb "github.com/google/battery-historian/pb/usagestats_proto"
+)
+
+// mainEntryFile is the file in a bugreport zip that names the main bugreport entry.
+const mainEntryFile = "main_entry.txt"
+
+// partNumberRE matches the number of a bugreport part file, e.g. "bugreport-part12.txt".
+var partNumberRE = regexp.MustCompile(`(\d+)\.txt$`)
+
+// ParseZip parses the main bugreport file contained in the zip archive at the given path.
+// The main entry is the file named by main_entry.txt if present, otherwise the first
+// bugreport*.txt file in the archive. The result is the same as calling Parse on the extracted file.
+func ParseZip(pkgs []*usagepb.PackageInfo, zipPath string) LogsData {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return LogsData{Errs: []error{fmt.Errorf("failed to open ZIP file: %v", err)}}
+	}
+	defer r.Close()
+	return parseZip(pkgs, &r.Reader)
+}
+
+// parseZip parses the main bugreport file in the given zip reader.
+func parseZip(pkgs []*usagepb.PackageInfo, r *zip.Reader) LogsData {
+	f, err := mainBugReportEntry(r)
+	if err != nil {
+		return LogsData{Errs: []error{err}}
+	}
+	contents, err := readZipFile(f)
+	if err != nil {
+		return LogsData{Errs: []error{err}}
+	}
+	return Parse(pkgs, contents)
+}
+
+// mainBugReportEntry returns the main bugreport file in the zip.
+func mainBugReportEntry(r *zip.Reader) (*zip.File, error) {
+	var mainName string
+	for _, f := range r.File {
+		if f.Name != mainEntryFile {
+			continue
+		}
+		contents, err := readZipFile(f)
+		if err != nil {
+			return nil, err
+		}
+		mainName = strings.TrimSpace(contents)
+	}
+	var candidate *zip.File
+	for _, f := range r.File {
+		if mainName != "" && f.Name == mainName {
+			return f, nil
+		}
+		if base := path.Base(f.Name); candidate == nil && strings.HasPrefix(base, "bugreport") && strings.HasSuffix(base, ".txt") {
+			candidate = f
+		}
+	}
+	if candidate == nil {
+		return nil, errors.New("no bugreport*.txt file found in ZIP file")
+	}
+	return candidate, nil
+}
+
+// readZipFile returns the contents of the given file in a zip archive.
+func readZipFile(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", fmt.Errorf("error reading %s from ZIP file: %v", f.Name, err)
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		return "", fmt.Errorf("error copying %s from ZIP file: %v", f.Name, err)
+	}
+	return string(b), nil
+}
+
+// ParseDir parses a bugreport that has been split into numbered bugreport*.txt parts in the given
+// directory. The parts are concatenated in numeric order, so part10 follows part9, before parsing.
+func ParseDir(pkgs []*usagepb.PackageInfo, dir string) LogsData {
+	contents, err := readSplitBugReport(dir)
+	if err != nil {
+		return LogsData{Errs: []error{err}}
+	}
+	return Parse(pkgs, contents)
+}
+
+// readSplitBugReport returns the concatenated contents of the bugreport parts in the given directory.
+func readSplitBugReport(dir string) (string, error) {
+	parts, err := filepath.Glob(filepath.Join(dir, "bugreport*.txt"))
+	if err != nil {
+		return "", err
+	}
+	if len(parts) == 0 {
+		return "", fmt.Errorf("no bugreport*.txt files found in %s", dir)
+	}
+	sort.Slice(parts, func(i, j int) bool {
+		ni, iok := partNumber(parts[i])
+		nj, jok := partNumber(parts[j])
+		if iok && jok && ni != nj {
+			return ni < nj
+		}
+		return parts[i] < parts[j]
+	})
+	var b strings.Builder
+	for _, p := range parts {
+		contents, err := os.ReadFile(p)
+		if err != nil {
+			return "", fmt.Errorf("error reading bugreport part: %v", err)
+		}
+		// Parts may be split mid-line, so they're joined without adding separators.
+		b.Write(contents)
+	}
+	return b.String(), nil
+}
+
+// partNumber returns the number at the end of the given bugreport part file name.
+func partNumber(name string) (int, bool) {
+	m := partNumberRE.FindStringSubmatch(filepath.Base(name))
+	if m == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(m[1])
+	return n, err == nil
+}
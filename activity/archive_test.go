@@ -0,0 +1,122 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package activity
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// archiveTestReport is a minimal bugreport containing a single system log event.
+var archiveTestReport = strings.Join([]string{
+	bugreportHeader(),
+	"------ SYSTEM LOG (logcat -v threadtime -d *:v) ------",
+	"09-27 20:49:00.000  1963  1976 W ActivityManager: WATCHDOG KILLING SYSTEM PROCESS",
+}, "\n")
+
+// newTestZip returns a zip reader over an in-memory archive containing the given files.
+func newTestZip(t *testing.T, files map[string]string) *zip.Reader {
+	t.Helper()
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	for name, contents := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("Create(%q) failed: %v", name, err)
+		}
+		if _, err := f.Write([]byte(contents)); err != nil {
+			t.Fatalf("Write(%q) failed: %v", name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatalf("zip.NewReader() failed: %v", err)
+	}
+	return r
+}
+
+// TestParseZip tests that parsing a bugreport zip gives the same result as parsing the flat bugreport.
+func TestParseZip(t *testing.T) {
+	tests := []struct {
+		desc  string
+		files map[string]string
+	}{
+		{
+			desc: "Bugreport entry found by name",
+			files: map[string]string{
+				"version.txt":                           "2.0",
+				"bugreport-device-2015-09-27-20-44.txt": archiveTestReport,
+			},
+		},
+		{
+			desc: "Bugreport entry named by main_entry.txt",
+			files: map[string]string{
+				mainEntryFile:           "bugreport-main.txt",
+				"bugreport-main.txt":    archiveTestReport,
+				"FS/data/bugreport.txt": "not a bugreport",
+			},
+		},
+	}
+	want := Parse(nil, archiveTestReport)
+	for _, test := range tests {
+		got := parseZip(nil, newTestZip(t, test.files))
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: parseZip() = %v, want %v", test.desc, got, want)
+		}
+	}
+}
+
+// TestParseZipMissingBugReport tests that an error is returned if the zip contains no bugreport.
+func TestParseZipMissingBugReport(t *testing.T) {
+	got := parseZip(nil, newTestZip(t, map[string]string{"version.txt": "2.0"}))
+	if len(got.Errs) != 1 {
+		t.Errorf("parseZip() errors = %v, want 1 error", got.Errs)
+	}
+}
+
+// TestParseDir tests that a bugreport split into numbered parts is reassembled in order.
+func TestParseDir(t *testing.T) {
+	dir := t.TempDir()
+	// Split mid-line, and number the parts so lexical and numeric order differ.
+	split := strings.Index(archiveTestReport, "WATCHDOG")
+	parts := map[string]string{
+		"bugreport-part2.txt":  archiveTestReport[:split/2],
+		"bugreport-part10.txt": archiveTestReport[split/2 : split],
+		"bugreport-part11.txt": archiveTestReport[split:],
+		"notes.txt":            "not part of the bugreport",
+	}
+	for name, contents := range parts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatalf("WriteFile(%q) failed: %v", name, err)
+		}
+	}
+
+	want := Parse(nil, archiveTestReport)
+	got := ParseDir(nil, dir)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseDir() = %v, want %v", got, want)
+	}
+	if len(got.Logs) == 0 {
+		t.Errorf("ParseDir() found no logs, want the system log")
+	}
+}
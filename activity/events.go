@@ -0,0 +1,285 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package activity
+
+// events.go converts the generated CSV into structured events and other output formats.
+
+import (
+	"bytes"
+	stdcsv "encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/battery-historian/csv"
+)
+
+// sectionOrder is the order log sections are processed in when comparing events across sections.
+var sectionOrder = []string{EventLogSection, SystemLogSection, LastLogcatSection}
+
+// Sections returns the names of the log sections found, with the known sections first in the order
+// they are processed, followed by any others in alphabetical order.
+func (d LogsData) Sections() []string {
+	var res, others []string
+	known := make(map[string]bool)
+	for _, s := range sectionOrder {
+		known[s] = true
+		if _, ok := d.Logs[s]; ok {
+			res = append(res, s)
+		}
+	}
+	for s := range d.Logs {
+		if !known[s] {
+			others = append(others, s)
+		}
+	}
+	sort.Strings(others)
+	return append(res, others...)
+}
+
+// Event is a single activity event along with the metric it was reported under.
+type Event struct {
+	Metric string
+	csv.Event
+}
+
+// Events returns the events contained in the log's CSV, in the order they were output.
+// Times may be in any of the csv.TimestampFormats.
+func (l *Log) Events() ([]Event, error) {
+	r := stdcsv.NewReader(strings.NewReader(l.CSV))
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var events []Event
+	for i, rec := range records {
+		if strings.Join(rec, ",") == csv.FileHeader {
+			continue
+		}
+		if len(rec) != 6 {
+			return nil, fmt.Errorf("record %d: got %d fields, want 6", i, len(rec))
+		}
+		start, err := csv.ParseTimestamp(rec[2])
+		if err != nil {
+			return nil, fmt.Errorf("record %d: invalid start time: %v", i, err)
+		}
+		end, err := csv.ParseTimestamp(rec[3])
+		if err != nil {
+			return nil, fmt.Errorf("record %d: invalid end time: %v", i, err)
+		}
+		events = append(events, Event{
+			Metric: rec[0],
+			Event: csv.Event{
+				Type:  rec[1],
+				Start: start,
+				End:   end,
+				Value: rec[4],
+				Opt:   rec[5],
+			},
+		})
+	}
+	return events, nil
+}
+
+// ndjsonEvent is the JSON representation of an event. The keys match the CSV header columns.
+type ndjsonEvent struct {
+	Metric string `json:"metric"`
+	Type   string `json:"type"`
+	Start  int64  `json:"start_time"`
+	End    int64  `json:"end_time"`
+	Value  string `json:"value"`
+	Opt    string `json:"opt"`
+}
+
+// WriteEventsNDJSON writes the events to w as newline delimited JSON, with one object per event.
+func WriteEventsNDJSON(w io.Writer, events []Event) error {
+	enc := json.NewEncoder(w)
+	for _, e := range events {
+		if err := enc.Encode(ndjsonEvent{
+			Metric: e.Metric,
+			Type:   e.Type,
+			Start:  e.Start,
+			End:    e.End,
+			Value:  e.Value,
+			Opt:    e.Opt,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dedupKey identifies the events that are considered the same when reported in different log sections.
+type dedupKey struct {
+	kind, payload string
+}
+
+// reportedEvent is an event seen in a log section, used for deduplication.
+type reportedEvent struct {
+	section string
+	start   int64
+}
+
+// eventDedupKey returns the key used to detect the same event reported in different log sections.
+func eventDedupKey(e Event) dedupKey {
+	switch e.Metric {
+	case "ANR":
+		// Event log format: User,pid,Package Name,Flags,reason.
+		if parts := strings.Split(e.Value, ","); len(parts) >= 3 {
+			return dedupKey{"ANR", parts[2]}
+		}
+	case "ANR Detected":
+		// System log format: ANR in <package> ...
+		if parts := strings.Fields(e.Value); len(parts) >= 3 {
+			return dedupKey{"ANR", parts[2]}
+		}
+	}
+	return dedupKey{e.Metric, e.Value}
+}
+
+// dedupSections removes events that were already reported in an earlier log section within the given window.
+// The CSV of a section is only rewritten if events were removed from it.
+func dedupSections(logs map[string]*Log, window time.Duration) []error {
+	var errs []error
+	windowMs := window.Nanoseconds() / int64(time.Millisecond)
+	seen := make(map[dedupKey][]reportedEvent)
+	for _, section := range sectionOrder {
+		l := logs[section]
+		if l == nil {
+			continue
+		}
+		events, err := l.Events()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: could not deduplicate events: %v", section, err))
+			continue
+		}
+		var kept []Event
+		for _, e := range events {
+			k := eventDedupKey(e)
+			dup := false
+			for _, r := range seen[k] {
+				if r.section != section && abs(r.start-e.Start) <= windowMs {
+					dup = true
+					break
+				}
+			}
+			if dup {
+				continue
+			}
+			seen[k] = append(seen[k], reportedEvent{section, e.Start})
+			kept = append(kept, e)
+		}
+		if len(kept) != len(events) {
+			l.CSV = eventsCSV(kept, csv.EpochMillis)
+		}
+	}
+	return errs
+}
+
+// sortSections stable sorts the events in each log section by start time.
+// The CSV of a section is only rewritten if its events were out of order.
+func sortSections(logs map[string]*Log) []error {
+	var errs []error
+	for _, section := range sectionOrder {
+		l := logs[section]
+		if l == nil {
+			continue
+		}
+		events, err := l.Events()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: could not sort events: %v", section, err))
+			continue
+		}
+		less := func(i, j int) bool { return events[i].Start < events[j].Start }
+		if sort.SliceIsSorted(events, less) {
+			continue
+		}
+		sort.SliceStable(events, less)
+		l.CSV = eventsCSV(events, csv.EpochMillis)
+	}
+	return errs
+}
+
+// relativeSections rewrites the events in each log section to be relative to the earliest event
+// start time of any section, and returns that time.
+func relativeSections(logs map[string]*Log) (int64, []error) {
+	var errs []error
+	events := make(map[string][]Event)
+	var base int64
+	found := false
+	for _, section := range sectionOrder {
+		l := logs[section]
+		if l == nil {
+			continue
+		}
+		evs, err := l.Events()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: could not make event times relative: %v", section, err))
+			continue
+		}
+		events[section] = evs
+		for _, e := range evs {
+			if !found || e.Start < base {
+				base = e.Start
+				found = true
+			}
+		}
+	}
+	for section, evs := range events {
+		for i := range evs {
+			evs[i].Start -= base
+			evs[i].End -= base
+		}
+		logs[section].CSV = eventsCSV(evs, csv.EpochMillis)
+		logs[section].StartMs -= base
+	}
+	return base, errs
+}
+
+// formatSections rewrites the events in each log section with times in the given format.
+// Sections are formatted last, as the other rewrites read times in ms.
+func formatSections(logs map[string]*Log, f csv.TimestampFormat) []error {
+	var errs []error
+	for section, l := range logs {
+		events, err := l.Events()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: could not format event times: %v", section, err))
+			continue
+		}
+		l.CSV = eventsCSV(events, f)
+	}
+	return errs
+}
+
+// eventsCSV returns the events in CSV format, including the header, with times in the given format.
+func eventsCSV(events []Event, f csv.TimestampFormat) string {
+	var b bytes.Buffer
+	s := csv.NewStateWithOptions(&b, true, csv.WriterOptions{TimestampFormat: f})
+	for _, e := range events {
+		s.PrintEvent(e.Metric, e.Event)
+	}
+	return b.String()
+}
+
+// abs returns the absolute value of x.
+func abs(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
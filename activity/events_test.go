@@ -0,0 +1,298 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package activity
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/battery-historian/csv"
+)
+
+// TestLogEvents tests the conversion of a log's CSV into events.
+func TestLogEvents(t *testing.T) {
+	l := &Log{
+		CSV: strings.Join([]string{
+			csv.FileHeader,
+			`ANR,service,1443411899609,1443411899609,"0,2103,com.google.android.gms,-1194836283,executing service",`,
+			`Activity Destroyed,service,1443411899700,1443411899700,com.google.android.gm/.Main,10023`,
+		}, "\n"),
+	}
+	got, err := l.Events()
+	if err != nil {
+		t.Fatalf("Events() unexpected error: %v", err)
+	}
+	want := []Event{
+		{Metric: "ANR", Event: csv.Event{Type: "service", Start: 1443411899609, End: 1443411899609, Value: "0,2103,com.google.android.gms,-1194836283,executing service"}},
+		{Metric: "Activity Destroyed", Event: csv.Event{Type: "service", Start: 1443411899700, End: 1443411899700, Value: "com.google.android.gm/.Main", Opt: "10023"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Events() = %v, want %v", got, want)
+	}
+}
+
+// TestWriteEventsNDJSON tests that each event is written as a separate line of valid JSON.
+func TestWriteEventsNDJSON(t *testing.T) {
+	events := []Event{
+		{Metric: "ANR", Event: csv.Event{Type: "service", Start: 1000, End: 1000, Value: "0,2103,com.google.android.gms"}},
+		{Metric: "Activity Destroyed", Event: csv.Event{Type: "service", Start: 2000, End: 2000, Value: "com.google.android.gm/.Main", Opt: "10023"}},
+	}
+	var b bytes.Buffer
+	if err := WriteEventsNDJSON(&b, events); err != nil {
+		t.Fatalf("WriteEventsNDJSON() unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if len(lines) != len(events) {
+		t.Fatalf("WriteEventsNDJSON() wrote %d lines, want %d:\n%s", len(lines), len(events), b.String())
+	}
+	wantKeys := []string{"end_time", "metric", "opt", "start_time", "type", "value"}
+	for i, l := range lines {
+		var obj map[string]interface{}
+		if err := json.Unmarshal([]byte(l), &obj); err != nil {
+			t.Fatalf("line %d is not valid JSON: %q: %v", i, l, err)
+		}
+		var keys []string
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		if !reflect.DeepEqual(keys, wantKeys) {
+			t.Errorf("line %d keys = %v, want %v", i, keys, wantKeys)
+		}
+		if obj["metric"] != events[i].Metric {
+			t.Errorf("line %d metric = %v, want %q", i, obj["metric"], events[i].Metric)
+		}
+	}
+}
+
+// TestParseDedupsAcrossSections tests that the same ANR reported in the event log and system log is only output once.
+func TestParseDedupsAcrossSections(t *testing.T) {
+	input := strings.Join([]string{
+		bugreportHeader(),
+		`------ EVENT LOG (logcat -b events -v threadtime -d *:v) ------`,
+		`09-27 20:44:59.609   808   822 I am_anr  : [0,2103,com.example.app,-1194836283,Input dispatching timed out]`,
+		`------ SYSTEM LOG (logcat -v threadtime -d *:v) ------`,
+		`09-27 20:45:00.100   808   822 E ActivityManager: ANR in com.example.app`,
+		`09-27 20:46:00.000   808   822 E ActivityManager: ANR in com.example.other`,
+	}, "\n")
+
+	tests := []struct {
+		desc    string
+		opts    Options
+		wantANR int
+	}{
+		{
+			desc:    "Default window dedups",
+			opts:    DefaultOptions(),
+			wantANR: 2,
+		},
+		{
+			desc:    "Deduplication disabled",
+			opts:    Options{DedupWindow: -1},
+			wantANR: 3,
+		},
+		{
+			desc:    "Window smaller than the gap",
+			opts:    Options{DedupWindow: 100 * time.Millisecond},
+			wantANR: 3,
+		},
+	}
+	for _, test := range tests {
+		res := ParseWithOptions(nil, input, test.opts)
+		if len(res.Errs) > 0 {
+			t.Errorf("%v: ParseWithOptions() unexpected errors: %v", test.desc, res.Errs)
+		}
+		got := 0
+		for _, l := range res.Logs {
+			events, err := l.Events()
+			if err != nil {
+				t.Fatalf("%v: Events() unexpected error: %v", test.desc, err)
+			}
+			for _, e := range events {
+				if e.Metric == "ANR" || e.Metric == "ANR Detected" {
+					got++
+				}
+			}
+		}
+		if got != test.wantANR {
+			t.Errorf("%v: ParseWithOptions() output %d ANR events, want %d:\n%v", test.desc, got, test.wantANR, res)
+		}
+	}
+}
+
+// TestParseTimestampFormat tests that events are deduplicated and sorted the same way when the CSV
+// times are not written in ms.
+func TestParseTimestampFormat(t *testing.T) {
+	input := strings.Join([]string{
+		bugreportHeader(),
+		`------ EVENT LOG (logcat -b events -v threadtime -d *:v) ------`,
+		`09-27 20:44:59.609   808   822 I am_anr  : [0,2103,com.example.app,-1194836283,Input dispatching timed out]`,
+		`------ SYSTEM LOG (logcat -v threadtime -d *:v) ------`,
+		`09-27 20:46:00.000   808   822 E ActivityManager: ANR in com.example.other`,
+		`09-27 20:45:00.100   808   822 E ActivityManager: ANR in com.example.app`,
+	}, "\n")
+	sorted, relative := DefaultOptions(), DefaultOptions()
+	sorted.SortByStart = true
+	relative.RelativeTimestamps = true
+
+	for _, opts := range []Options{sorted, relative} {
+		parse := func(f csv.TimestampFormat) (map[string][]Event, []string) {
+			opts.TimestampFormat = f
+			res := ParseWithOptions(nil, input, opts)
+			events := make(map[string][]Event)
+			for section, l := range res.Logs {
+				evs, err := l.Events()
+				if err != nil {
+					t.Fatalf("%v: %s: Events() unexpected error: %v", f, section, err)
+				}
+				events[section] = evs
+			}
+			var errs []string
+			for _, err := range res.Errs {
+				errs = append(errs, err.Error())
+			}
+			return events, errs
+		}
+		want, wantErrs := parse(csv.EpochMillis)
+		for _, f := range []csv.TimestampFormat{csv.EpochSeconds, csv.ISO8601} {
+			got, errs := parse(f)
+			if !reflect.DeepEqual(errs, wantErrs) {
+				t.Errorf("%v: ParseWithOptions(%+v) errors = %q, want %q", f, opts, errs, wantErrs)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%v: ParseWithOptions(%+v) events = %v, want %v", f, opts, got, want)
+			}
+		}
+	}
+
+	sorted.TimestampFormat = csv.ISO8601
+	if got := ParseWithOptions(nil, input, sorted).Logs[SystemLogSection].CSV; !strings.Contains(got, "2015-09-27T20:46:00.000Z") {
+		t.Errorf("ParseWithOptions() with ISO8601 wrote:\n%s\nwant ISO 8601 times", got)
+	}
+	if got := Parse(nil, input).Logs[SystemLogSection].CSV; !strings.Contains(got, "1443386760000") {
+		t.Errorf("Parse() wrote:\n%s\nwant times in ms", got)
+	}
+}
+
+// TestParseSortByStart tests that events output out of order are sorted by start time when requested.
+func TestParseSortByStart(t *testing.T) {
+	input := strings.Join([]string{
+		bugreportHeader(),
+		`------ SYSTEM LOG (logcat -v threadtime -d *:v) ------`,
+		`09-27 20:46:00.000   808   822 E ActivityManager: ANR in com.example.later`,
+		`09-27 20:45:00.000   808   822 E ActivityManager: ANR in com.example.earlier`,
+		`09-27 20:45:00.000   808   822 W ActivityManager: WATCHDOG KILLING SYSTEM PROCESS`,
+	}, "\n")
+
+	tests := []struct {
+		desc        string
+		sortByStart bool
+		want        []string
+	}{
+		{
+			desc:        "Log order",
+			sortByStart: false,
+			want:        []string{"ANR in com.example.later", "ANR in com.example.earlier", "WATCHDOG KILLING SYSTEM PROCESS"},
+		},
+		{
+			desc:        "Sorted by start, keeping the log order of ties",
+			sortByStart: true,
+			want:        []string{"ANR in com.example.earlier", "WATCHDOG KILLING SYSTEM PROCESS", "ANR in com.example.later"},
+		},
+	}
+	for _, test := range tests {
+		opts := DefaultOptions()
+		opts.SortByStart = test.sortByStart
+		res := ParseWithOptions(nil, input, opts)
+		l := res.Logs[SystemLogSection]
+		if l == nil {
+			t.Fatalf("%v: ParseWithOptions() missing %q section", test.desc, SystemLogSection)
+		}
+		events, err := l.Events()
+		if err != nil {
+			t.Fatalf("%v: Events() unexpected error: %v", test.desc, err)
+		}
+		var got []string
+		for _, e := range events {
+			got = append(got, e.Value)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: ParseWithOptions() events = %q, want %q", test.desc, got, test.want)
+		}
+	}
+}
+
+// TestLogsDataSections tests that sections are returned in a deterministic order.
+func TestLogsDataSections(t *testing.T) {
+	d := LogsData{
+		Logs: map[string]*Log{
+			LastLogcatSection: {},
+			"OTHER LOG":       {},
+			EventLogSection:   {},
+			SystemLogSection:  {},
+			"ANOTHER LOG":     {},
+		},
+	}
+	want := []string{EventLogSection, SystemLogSection, LastLogcatSection, "ANOTHER LOG", "OTHER LOG"}
+	for i := 0; i < 10; i++ {
+		if got := d.Sections(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("Sections() = %v, want %v", got, want)
+		}
+	}
+}
+
+// TestParseRelativeTimestamps tests that event times are output relative to the first event.
+func TestParseRelativeTimestamps(t *testing.T) {
+	input := strings.Join([]string{
+		bugreportHeader(),
+		`------ SYSTEM LOG (logcat -v threadtime -d *:v) ------`,
+		`09-27 20:45:00.000   808   822 E ActivityManager: ANR in com.example.app`,
+		`------ EVENT LOG (logcat -b events -v threadtime -d *:v) ------`,
+		`09-27 20:44:59.000   808   822 I am_anr  : [0,2103,com.example.other,-1194836283,Input dispatching timed out]`,
+	}, "\n")
+
+	opts := DefaultOptions()
+	opts.RelativeTimestamps = true
+	res := ParseWithOptions(nil, input, opts)
+	if len(res.Errs) > 0 {
+		t.Errorf("ParseWithOptions() unexpected errors: %v", res.Errs)
+	}
+	// The base should be the absolute time of the event log event, which is the first event.
+	abs, err := ParseWithOptions(nil, input, DefaultOptions()).Logs[EventLogSection].Events()
+	if err != nil || len(abs) != 1 {
+		t.Fatalf("Events() = %v, %v, want one event", abs, err)
+	}
+	if res.BaseTimestampMs != abs[0].Start {
+		t.Errorf("ParseWithOptions() BaseTimestampMs = %d, want %d", res.BaseTimestampMs, abs[0].Start)
+	}
+	want := map[string]int64{
+		EventLogSection:  0,
+		SystemLogSection: 1000,
+	}
+	for section, start := range want {
+		events, err := res.Logs[section].Events()
+		if err != nil {
+			t.Fatalf("%s: Events() unexpected error: %v", section, err)
+		}
+		if len(events) != 1 || events[0].Start != start || events[0].End != start {
+			t.Errorf("%s: ParseWithOptions() events = %v, want one event at relative time %d", section, events, start)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package activity
+
+// handlers.go allows users to extend the log parsing with their own line handlers.
+
+import (
+	"sync"
+)
+
+// lineHandler is a user registered handler for log lines.
+type lineHandler struct {
+	matcher func(line string) bool
+	handler func(line string) (Event, bool)
+}
+
+var (
+	lineHandlersMu sync.RWMutex
+	lineHandlers   []lineHandler
+)
+
+// RegisterLineHandler registers a handler for log lines not otherwise understood by the parser,
+// such as vendor specific log tags. For every log line in a parsed log section for which matcher
+// returns true, handler is called with the full line, and the returned event is output if the
+// boolean is true. Registered handlers run after the built-in event parsing, in registration order.
+// If the returned event has no start time, the timestamp of the log line is used, and if it has
+// no end time, it is output as an instant event. An empty Type defaults to "service".
+func RegisterLineHandler(matcher func(line string) bool, handler func(line string) (Event, bool)) {
+	lineHandlersMu.Lock()
+	defer lineHandlersMu.Unlock()
+	lineHandlers = append(lineHandlers, lineHandler{matcher, handler})
+}
+
+// resetLineHandlers removes all registered line handlers.
+func resetLineHandlers() {
+	lineHandlersMu.Lock()
+	defer lineHandlersMu.Unlock()
+	lineHandlers = nil
+}
+
+// runLineHandlers outputs the events returned by any registered handlers matching the line.
+func (p *parser) runLineHandlers(timestamp int64, line string) {
+	lineHandlersMu.RLock()
+	defer lineHandlersMu.RUnlock()
+	for _, h := range lineHandlers {
+		if !h.matcher(line) {
+			continue
+		}
+		e, ok := h.handler(line)
+		if !ok {
+			continue
+		}
+		if e.Start == 0 {
+			e.Start = timestamp
+		}
+		if e.End == 0 {
+			e.End = e.Start
+		}
+		if e.Type == "" {
+			e.Type = "service"
+		}
+		p.csvState.PrintEvent(e.Metric, e.Event)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package activity
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/battery-historian/csv"
+)
+
+// TestRegisterLineHandler tests that events from a registered handler for a custom tag are output.
+func TestRegisterLineHandler(t *testing.T) {
+	defer resetLineHandlers()
+	RegisterLineHandler(
+		func(line string) bool { return strings.Contains(line, "VendorModem:") },
+		func(line string) (Event, bool) {
+			i := strings.Index(line, "throttle=")
+			if i < 0 {
+				return Event{}, false
+			}
+			return Event{
+				Metric: "Vendor Modem Throttle",
+				Event:  csv.Event{Value: line[i+len("throttle="):]},
+			}, true
+		},
+	)
+
+	input := strings.Join([]string{
+		bugreportHeader(),
+		"------ SYSTEM LOG (logcat -v threadtime -d *:v) ------",
+		"09-27 20:44:00.000  1234  1235 I VendorModem: throttle=high",
+		"09-27 20:44:01.000  1234  1235 I VendorModem: idle",
+	}, "\n")
+
+	res := Parse(nil, input)
+	events, err := res.Logs[SystemLogSection].Events()
+	if err != nil {
+		t.Fatalf("Events() unexpected error: %v", err)
+	}
+	var got []Event
+	for _, e := range events {
+		if e.Metric == "Vendor Modem Throttle" {
+			got = append(got, e)
+		}
+	}
+	if len(got) != 1 {
+		t.Fatalf("Parse() output %d custom events, want 1:\n%s", len(got), res.Logs[SystemLogSection].CSV)
+	}
+	if got[0].Value != "high" || got[0].Type != "service" || got[0].Start == 0 || got[0].Start != got[0].End {
+		t.Errorf("Parse() custom event = %+v, want an instant service event with value high", got[0])
+	}
+}
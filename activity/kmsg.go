@@ -0,0 +1,92 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package activity
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/battery-historian/bugreportutils"
+	"github.com/google/battery-historian/csv"
+	"github.com/google/battery-historian/historianutils"
+)
+
+// LastKmsgSection is the heading found before the start of the kernel log from the previous boot.
+// e.g. "------ LAST KMSG (/proc/last_kmsg) ------"
+const LastKmsgSection = "LAST KMSG"
+
+var (
+	// kmsgLineRE is a regular expression that matches a kernel log line, which is prefixed by the
+	// time in seconds since boot.
+	// e.g. "[  123.456789] Kernel panic - not syncing: Fatal exception"
+	kmsgLineRE = regexp.MustCompile(`^(?:<\d+>)?\[\s*(?P<secs>\d+\.\d+)\]\s*(?P<message>.*)$`)
+
+	// kernelPanicRE is a regular expression that matches the kernel logging a panic.
+	// e.g. "Kernel panic - not syncing: Fatal exception in interrupt"
+	kernelPanicRE = regexp.MustCompile(`Kernel panic - not syncing:\s*(?P<reason>.*)`)
+
+	// kernelRebootRE is a regular expression that matches the kernel logging a reboot and its reason.
+	// e.g. "reboot: Restarting system with command 'shutdown,thermal'"
+	kernelRebootRE = regexp.MustCompile(`Restarting system(?: with command '(?P<reason>[^']*)')?`)
+)
+
+// ParseLastKmsg returns a "Kernel Panic" or "Reboot" event for each kernel panic or reboot logged in
+// the LAST KMSG section of the bugreport, with the reason as the value. The kernel log only records
+// the time since the previous boot, so the start time of each event is in ms since that boot, rather
+// than since the Unix epoch. Errors encountered during parsing will be collected into an errors slice
+// and will continue parsing remaining lines.
+func ParseLastKmsg(f string) ([]csv.Entry, []error) {
+	var entries []csv.Entry
+	var errs []error
+	inSection := false
+	for _, line := range strings.Split(f, "\n") {
+		if m, result := historianutils.SubexpNames(bugreportutils.BugReportSectionRE, line); m && strings.HasPrefix(line, "-") {
+			inSection = strings.HasPrefix(result["section"], LastKmsgSection)
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		m, result := historianutils.SubexpNames(kmsgLineRE, line)
+		if !m {
+			continue
+		}
+		desc, reason := "", ""
+		if m, r := historianutils.SubexpNames(kernelPanicRE, result["message"]); m {
+			desc, reason = "Kernel Panic", r["reason"]
+		} else if m, r := historianutils.SubexpNames(kernelRebootRE, result["message"]); m {
+			desc, reason = csv.Reboot, r["reason"]
+		} else {
+			continue
+		}
+		secs, err := strconv.ParseFloat(result["secs"], 64)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not parse kernel time %q: %v", result["secs"], err))
+			continue
+		}
+		ms := int64(math.Round(secs * 1000))
+		entries = append(entries, csv.Entry{
+			Desc:  desc,
+			Start: ms,
+			End:   ms,
+			Type:  "service",
+			Value: strings.TrimSpace(reason),
+		})
+	}
+	return entries, errs
+}
@@ -0,0 +1,49 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package activity
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/battery-historian/csv"
+)
+
+// TestParseLastKmsg tests the extraction of kernel panic and reboot events from the last kernel log.
+func TestParseLastKmsg(t *testing.T) {
+	input := strings.Join([]string{
+		bugreportHeader(),
+		"------ LAST KMSG (/proc/last_kmsg) ------",
+		"[    0.000000] Booting Linux on physical CPU 0x0",
+		"[ 5123.456789] Unable to handle kernel NULL pointer dereference at virtual address 00000008",
+		"[ 5123.460000] Kernel panic - not syncing: Fatal exception in interrupt",
+		"<6>[ 7200.000000] reboot: Restarting system with command 'shutdown,thermal'",
+		"------ SYSTEM LOG (logcat -v threadtime -d *:v) ------",
+		"[ 9999.000000] Kernel panic - not syncing: not in the last kmsg section",
+	}, "\n")
+
+	want := []csv.Entry{
+		{Desc: "Kernel Panic", Type: "service", Start: 5123460, End: 5123460, Value: "Fatal exception in interrupt"},
+		{Desc: "Reboot", Type: "service", Start: 7200000, End: 7200000, Value: "shutdown,thermal"},
+	}
+	got, errs := ParseLastKmsg(input)
+	if len(errs) > 0 {
+		t.Errorf("ParseLastKmsg() got unexpected errors: %v", errs)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseLastKmsg() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package activity
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/battery-historian/bugreportutils"
+	"github.com/google/battery-historian/csv"
+	"github.com/google/battery-historian/historianutils"
+
+	usagepb "github.com/google/battery-historian/pb/usagestats_proto"
+)
+
+// maxStreamLineBytes is the longest log line ParseStream can read.
+const maxStreamLineBytes = 1024 * 1024
+
+// StreamMeta contains the bugreport information needed to parse log lines, which Parse would
+// otherwise extract from the whole bugreport.
+type StreamMeta struct {
+	// Header is the part of the bugreport containing the dumpstate and timezone lines, and optionally
+	// the process list used to match PIDs to apps. Usually this is the text before the first log section.
+	Header string
+	// Pkgs is the package info used to match events to UIDs.
+	Pkgs []*usagepb.PackageInfo
+}
+
+// ParseStream is the same as Parse, but reads the bugreport from r, and writes each CSV row to w as
+// soon as the event is recognized, rather than building the CSV of each log section in memory.
+// The rows of all the log sections are written to w in the order they're found, preceded by a single
+// CSV header. Options that need all the events of a section, such as deduplication, aren't supported.
+// Errors encountered during parsing will be collected into an errors slice and will continue parsing
+// remaining events.
+func ParseStream(meta StreamMeta, r io.Reader, w io.Writer) ([]string, []error) {
+	p, warnings, err := newParser(meta.Header)
+	if err != nil {
+		return warnings, []error{err}
+	}
+	var errs []error
+	inLog, printHeader := false, true
+	var lastTimestamp int64
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, maxStreamLineBytes)
+	for sc.Scan() {
+		line := sc.Text()
+		if m, result := historianutils.SubexpNames(bugreportutils.BugReportSectionRE, line); m && strings.HasPrefix(line, "-") {
+			// Output any pending events of the previous section.
+			if inLog {
+				p.flushEvents(lastTimestamp)
+			}
+			inLog = logSection(result["section"]) != ""
+			if inLog {
+				p.csvState = csv.NewState(w, printHeader)
+				printHeader = false
+			}
+			continue
+		}
+		if !inLog {
+			continue
+		}
+		m, result := historianutils.SubexpNames(logEntryRE, line)
+		if !m {
+			continue
+		}
+		timestamp, err := p.fullTimestamp(result["month"], result["day"], result["timeStamp"], result["remainder"])
+		lastTimestamp = timestamp
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		warning, err := p.parseEvent(meta.Pkgs, timestamp, result["event"], strings.TrimSpace(result["details"]), result["pid"])
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+		p.runLineHandlers(timestamp, line)
+	}
+	// Reached the end of the logs. Output any pending events.
+	if inLog {
+		p.flushEvents(lastTimestamp)
+	}
+	if err := sc.Err(); err != nil {
+		errs = append(errs, fmt.Errorf("could not read bugreport: %v", err))
+	}
+	return warnings, errs
+}
@@ -0,0 +1,48 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package activity
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestParseStream tests that the streamed CSV is the same as the CSV built by Parse.
+func TestParseStream(t *testing.T) {
+	logs := strings.Join([]string{
+		"------ SYSTEM LOG (logcat -v threadtime -d *:v) ------",
+		"--------- beginning of system",
+		"09-27 20:44:00.000  12345  12346 D BluetoothAdapter: startLeScan()",
+		"09-27 20:44:05.000  12345  12346 D BluetoothAdapter: stopLeScan()",
+		"09-27 20:55:00.000  1234  1500 I NotificationService: enqueueNotificationInternal: pkg=com.example.app id=1 notification=Notification(channel=chat pri=0)",
+		"09-27 20:57:00.000  1234  1500 I Vpn     : Established by com.example.vpn on tun0",
+		"09-27 20:58:00.000  1234  1500 D Vpn     : setting state=DISCONNECTED, reason=agentDisconnect",
+	}, "\n")
+	report := bugreportHeader() + "\n" + logs
+
+	want := ParseWithOptions(nil, report, Options{DedupWindow: -1}).Logs[SystemLogSection].CSV
+	var b bytes.Buffer
+	_, errs := ParseStream(StreamMeta{Header: bugreportHeader()}, strings.NewReader(report), &b)
+	if len(errs) > 0 {
+		t.Errorf("ParseStream() got unexpected errors: %v", errs)
+	}
+	if got := b.String(); got != want {
+		t.Errorf("ParseStream() wrote:\n%s\nwant the same as Parse:\n%s", got, want)
+	}
+	if strings.Count(want, "\n") < 5 {
+		t.Errorf("Parse() CSV has too few events to compare:\n%s", want)
+	}
+}
@@ -905,7 +905,9 @@ func (pd *ParsedData) parseBugReport(fnameA, contentsA, fnameB, contentsB string
 				CSV:    broadcastsOutput.csv,
 			},
 		}
-		for s, l := range activityManagerOutput.Logs {
+		// Iterate in section order so the logs are always returned in the same order.
+		for _, s := range activityManagerOutput.Sections() {
+			l := activityManagerOutput.Logs[s]
 			if l == nil {
 				log.Print("Nil logcat log received")
 				continue
@@ -0,0 +1,96 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analyzer
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/battery-historian/activity"
+	"github.com/google/battery-historian/parseutils"
+)
+
+// update regenerates the golden files from the current parser output, e.g.
+// "go test ./analyzer -run TestGolden -update".
+var update = flag.Bool("update", false, "update the golden files in testdata/golden")
+
+// goldenDir holds pairs of sample bug reports and their expected CSV output.
+// The expected output for "<name>.txt" is stored in "<name>.expected.csv".
+const goldenDir = "testdata/golden"
+
+// goldenCSV returns the output of the history and activity parsers for the given bug report,
+// with each source preceded by a "# <source>" line. Sources are always output in the same order.
+func goldenCSV(report string) string {
+	var b strings.Builder
+	add := func(source, csv string) {
+		fmt.Fprintf(&b, "# %s\n%s", source, csv)
+		if csv != "" && !strings.HasSuffix(csv, "\n") {
+			b.WriteString("\n")
+		}
+	}
+
+	s := analyze(report, nil)
+	add(batteryHistory, s.historianV2CSV)
+	for i, block := range parseutils.SplitHistoryV2Blocks(report) {
+		add(fmt.Sprintf("Battery History Format 2, block %d", i+1), parseutils.HistoryV2CSV(parseutils.ParseHistoryV2Block(block).Entries))
+	}
+	logs := activity.Parse(nil, report)
+	for _, section := range logs.Sections() {
+		add(section, logs.Logs[section].CSV)
+	}
+	return b.String()
+}
+
+// TestGolden parses each sample bug report in the golden directory and compares the CSV output
+// with the expected output, so regressions in the history and activity parsers are caught.
+func TestGolden(t *testing.T) {
+	reports, err := filepath.Glob(filepath.Join(goldenDir, "*.txt"))
+	if err != nil {
+		t.Fatalf("filepath.Glob() unexpected error: %v", err)
+	}
+	if len(reports) == 0 {
+		t.Fatalf("no sample bug reports found in %s", goldenDir)
+	}
+	for _, r := range reports {
+		contents, err := os.ReadFile(r)
+		if err != nil {
+			t.Fatalf("%v: could not read sample: %v", r, err)
+		}
+		got := goldenCSV(string(contents))
+		// Running it twice ensures the output doesn't depend on map iteration order.
+		if again := goldenCSV(string(contents)); again != got {
+			t.Errorf("%v: goldenCSV() output is not deterministic:\n%s\n---\n%s", r, got, again)
+		}
+
+		expectedPath := strings.TrimSuffix(r, ".txt") + ".expected.csv"
+		if *update {
+			if err := os.WriteFile(expectedPath, []byte(got), 0644); err != nil {
+				t.Fatalf("%v: could not update golden file: %v", expectedPath, err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(expectedPath)
+		if err != nil {
+			t.Fatalf("%v: could not read golden file: %v", expectedPath, err)
+		}
+		if got != string(want) {
+			t.Errorf("%v: output differs from %s (rerun with -update if the change is intended):\ngot:\n%s\nwant:\n%s", r, expectedPath, got, want)
+		}
+	}
+}
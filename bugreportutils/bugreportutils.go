@@ -46,11 +46,11 @@ var (
 	// deviceIDRE is a regular expression that matches the "DeviceID" line
 	deviceIDRE = regexp.MustCompile("DeviceID: (?P<deviceID>[0-9]+)")
 
-	// sdkVersionRE is a regular expression that finds sdk version in the System Properties section of a bug report
-	sdkVersionRE = regexp.MustCompile(`\[ro.build.version.sdk\]:\s+\[(?P<sdkVersion>\d+)\]`)
+	// SdkVersionRE is a regular expression that finds sdk version in the System Properties section of a bug report
+	SdkVersionRE = regexp.MustCompile(`\[ro.build.version.sdk\]:\s+\[(?P<sdkVersion>\d+)\]`)
 
-	// buildFingerprintRE is a regular expression to match any build fingerprint line in the bugreport
-	buildFingerprintRE = regexp.MustCompile(`Build\s+fingerprint:\s+'(?P<build>\S+)'`)
+	// BuildFingerprintRE is a regular expression to match any build fingerprint line in the bugreport
+	BuildFingerprintRE = regexp.MustCompile(`Build\s+fingerprint:\s+'(?P<build>\S+)'`)
 
 	// modelNameRE is a regular expression that finds the model name line in the System Properties section of a bug report.
 	modelNameRE = regexp.MustCompile(`\[ro.product.model\]:\s+\[(?P<modelName>.*)\]`)
@@ -94,7 +94,7 @@ func Contents(fname string, b []byte) (map[string][]byte, error) {
 // IsBugReport tries to determine if the given bytes resembles a bug report.
 func IsBugReport(b []byte) bool {
 	// Check for a few expected lines in all bug reports.
-	return DumpstateRE.Match(b) && buildFingerprintRE.Match(b) && BugReportSectionRE.Match(b)
+	return DumpstateRE.Match(b) && BuildFingerprintRE.Match(b) && BugReportSectionRE.Match(b)
 }
 
 // unzipAndExtract unzips the given application/zip format file and returns the contents of each file.
@@ -146,13 +146,13 @@ func ParseMetaInfo(input string) (*MetaInfo, error) {
 	for _, line := range strings.Split(input, "\n") {
 		if match, result := historianutils.SubexpNames(deviceIDRE, line); match {
 			deviceID = result["deviceID"]
-		} else if match, result := historianutils.SubexpNames(sdkVersionRE, line); match {
+		} else if match, result := historianutils.SubexpNames(SdkVersionRE, line); match {
 			sdk, err := strconv.Atoi(result["sdkVersion"])
 			if err != nil {
 				return nil, err
 			}
 			sdkVersion = sdk
-		} else if match, result := historianutils.SubexpNames(buildFingerprintRE, line); match && buildFingerprint == "" {
+		} else if match, result := historianutils.SubexpNames(BuildFingerprintRE, line); match && buildFingerprint == "" {
 			// Only the first instance of this line in the bug report is guaranteed to be correct.
 			// All following instances may be wrong, so we ignore them.
 			buildFingerprint = result["build"]
@@ -22,6 +22,7 @@ import (
 	"io"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -51,6 +52,10 @@ type Entry struct {
 
 	// Unique identifier for the event. e.g. The name of the app that triggered the event.
 	Identifier string
+
+	// End time of the entry. This is only set by conversions that already know both endpoints,
+	// such as intervals built from parsed history. It is zero for entries whose end is tracked by State.
+	End int64
 }
 
 // Functions expected by the EntryState interface.
@@ -143,6 +148,9 @@ type State struct {
 	curWakeupReason *wakeupReason
 
 	rebootEvent *Entry
+
+	// timestampFormat is the format start and end times are written in.
+	timestampFormat TimestampFormat
 }
 
 // Key is the unique identifier for an entry.
@@ -150,15 +158,21 @@ type Key struct {
 	Metric, Identifier string
 }
 
-// NewState returns a new State.
+// NewState returns a new State that writes times in ms, as expected by the Historian frontend.
 func NewState(csvWriter io.Writer, printHeader bool) *State {
+	return NewStateWithOptions(csvWriter, printHeader, WriterOptions{})
+}
+
+// NewStateWithOptions returns a new State that writes its output as specified by the options.
+func NewStateWithOptions(csvWriter io.Writer, printHeader bool, opts WriterOptions) *State {
 	// Write the csv header.
 	if csvWriter != nil && printHeader {
 		fmt.Fprintln(csvWriter, FileHeader)
 	}
 	return &State{
-		writer:  csv.NewWriter(csvWriter),
-		entries: make(map[Key]Entry),
+		writer:          csv.NewWriter(csvWriter),
+		entries:         make(map[Key]Entry),
+		timestampFormat: opts.TimestampFormat,
 	}
 }
 
@@ -247,6 +261,71 @@ func stripQuotes(value string) string {
 	return value
 }
 
+// TimestampFormat specifies how start and end times are written in the CSV output.
+type TimestampFormat int32
+
+const (
+	// EpochMillis writes times as milliseconds since the Unix epoch. This is the default, and the
+	// format expected by the Historian frontend.
+	EpochMillis TimestampFormat = iota
+	// EpochSeconds writes times as seconds since the Unix epoch, with millisecond precision.
+	// e.g. "1768132800.123"
+	EpochSeconds
+	// ISO8601 writes times in UTC as ISO 8601, with millisecond precision.
+	// e.g. "2026-01-11T12:00:00.123Z"
+	ISO8601
+)
+
+// WriterOptions specifies how CSV output is written.
+type WriterOptions struct {
+	// TimestampFormat is the format of the start and end times. The zero value writes times in ms.
+	TimestampFormat TimestampFormat
+}
+
+// FormatTimestamp formats the given time in ms since the Unix epoch in the given format.
+func FormatTimestamp(ms int64, f TimestampFormat) string {
+	switch f {
+	case EpochSeconds:
+		return strconv.FormatFloat(float64(ms)/1000, 'f', 3, 64)
+	case ISO8601:
+		return time.UnixMilli(ms).UTC().Format("2006-01-02T15:04:05.000Z07:00")
+	default:
+		return strconv.FormatInt(ms, 10)
+	}
+}
+
+// ParseTimestamp parses a time written by FormatTimestamp, and returns it in ms since the Unix epoch.
+// Any of the TimestampFormats is accepted, so CSV can be read back regardless of the format it was written in.
+func ParseTimestamp(s string) (int64, error) {
+	switch {
+	case strings.Contains(s, "T"):
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return 0, err
+		}
+		return t.UnixMilli(), nil
+	case strings.Contains(s, "."):
+		// Parse the seconds and fraction separately to avoid floating point rounding errors.
+		parts := strings.SplitN(s, ".", 2)
+		sec, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		frac := parts[1]
+		if frac == "" || len(frac) > 3 || strings.Trim(frac, "0123456789") != "" {
+			return 0, fmt.Errorf("invalid fractional seconds in %q", s)
+		}
+		// The fraction is padded so "1.5" is read as 1500 ms.
+		ms, _ := strconv.ParseInt((frac + "00")[:3], 10, 64)
+		if strings.HasPrefix(parts[0], "-") {
+			return sec*1000 - ms, nil
+		}
+		return sec*1000 + ms, nil
+	default:
+		return strconv.ParseInt(s, 10, 64)
+	}
+}
+
 // Print directly prints a csv entry to CSV format and writes it to the writer.
 func (s *State) Print(desc, metricType string, start, end int64, value, opt string) {
 	if s.writer == nil {
@@ -259,10 +338,63 @@ func (s *State) Print(desc, metricType string, start, end int64, value, opt stri
 	// CSV parsing on the JS side to treat the quotes as a text qualifier rather than part of the value.
 	value = stripQuotes(value)
 	opt = stripQuotes(opt)
-	s.writer.Write([]string{desc, metricType, strconv.FormatInt(start, 10), strconv.FormatInt(end, 10), value, opt})
+	s.writer.Write([]string{desc, metricType, FormatTimestamp(start, s.timestampFormat), FormatTimestamp(end, s.timestampFormat), value, opt})
 	s.writer.Flush()
 }
 
+// WriteEntries writes the entries to w as CSV rows, preceded by the header if printHeader is true.
+// Fields containing commas, quotes or newlines are quoted, and embedded quotes are doubled, as specified
+// by RFC 4180. Unlike Print, values are written as is, so quotes wrapping a value are kept.
+// Entries with no end time are written with an end time equal to their start time.
+// Times are written in ms.
+func WriteEntries(w io.Writer, entries []Entry, printHeader bool) error {
+	return WriteEntriesWithOptions(w, entries, printHeader, WriterOptions{})
+}
+
+// WriteEntriesWithOptions is like WriteEntries, but writes the entries as specified by the options.
+func WriteEntriesWithOptions(w io.Writer, entries []Entry, printHeader bool, opts WriterOptions) error {
+	if printHeader {
+		if _, err := fmt.Fprintln(w, FileHeader); err != nil {
+			return err
+		}
+	}
+	cw := csv.NewWriter(w)
+	for _, e := range entries {
+		end := e.End
+		if end == 0 {
+			end = e.Start
+		}
+		if err := cw.Write([]string{e.Desc, e.Type, FormatTimestamp(e.Start, opts.TimestampFormat), FormatTimestamp(end, opts.TimestampFormat), e.Value, e.Opt}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// GroupByType returns the entries grouped by their Type, so each type can be written to a separate
+// stream with WriteEntries. The Type is the kind of value of an entry, e.g. "bool" or "service",
+// so unrelated tracks of the same kind, such as CPU running and Screen, are in the same group.
+// Use GroupByMetric for one group per track. Entries within each group keep their relative order.
+func GroupByType(entries []Entry) map[string][]Entry {
+	res := make(map[string][]Entry)
+	for _, e := range entries {
+		res[e.Type] = append(res[e.Type], e)
+	}
+	return res
+}
+
+// GroupByMetric returns the entries grouped by their Desc, which is the metric name of the track
+// they belong to, so each track can be written to a separate stream with WriteEntries.
+// Entries within each group keep their relative order.
+func GroupByMetric(entries []Entry) map[string][]Entry {
+	res := make(map[string][]Entry)
+	for _, e := range entries {
+		res[e.Desc] = append(res[e.Desc], e)
+	}
+	return res
+}
+
 // PrintEvent writes an event extracted by ExtractEvents to the writer.
 func (s *State) PrintEvent(metric string, e Event) {
 	s.Print(metric, e.Type, e.Start, e.End, e.Value, e.Opt)
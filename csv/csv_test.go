@@ -0,0 +1,174 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestWriteEntries tests that values containing commas and quotes are escaped per RFC 4180.
+func TestWriteEntries(t *testing.T) {
+	entries := []Entry{
+		{
+			Desc:  "Battery state change",
+			Type:  "Battery State",
+			Start: 1000,
+			End:   1000,
+			Value: "status=discharging,health=good",
+		},
+		{
+			Desc:  "Wakeup reason",
+			Type:  "string",
+			Start: 2000,
+			Value: `"100 rtc_alarm"`,
+			Opt:   "1000",
+		},
+	}
+	var b bytes.Buffer
+	if err := WriteEntries(&b, entries, true); err != nil {
+		t.Fatalf("WriteEntries() unexpected error: %v", err)
+	}
+	want := strings.Join([]string{
+		FileHeader,
+		`Battery state change,Battery State,1000,1000,"status=discharging,health=good",`,
+		`Wakeup reason,string,2000,2000,"""100 rtc_alarm""",1000`,
+		``,
+	}, "\n")
+	if got := b.String(); got != want {
+		t.Errorf("WriteEntries() wrote:\n%s\nwant:\n%s", got, want)
+	}
+
+	// The output should round trip through a standard CSV reader.
+	records, err := csv.NewReader(strings.NewReader(b.String())).ReadAll()
+	if err != nil {
+		t.Fatalf("WriteEntries() output could not be read: %v", err)
+	}
+	if got := records[1][4]; got != entries[0].Value {
+		t.Errorf("WriteEntries() value read back = %q, want %q", got, entries[0].Value)
+	}
+	if got, want := records[2], []string{"Wakeup reason", "string", "2000", "2000", `"100 rtc_alarm"`, "1000"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("WriteEntries() record read back = %q, want %q", got, want)
+	}
+}
+
+// TestTimestampFormat tests that times are written in the format given in the options.
+func TestTimestampFormat(t *testing.T) {
+	tests := []struct {
+		format TimestampFormat
+		want   string
+	}{
+		{EpochMillis, "1768132800123"},
+		{EpochSeconds, "1768132800.123"},
+		{ISO8601, "2026-01-11T12:00:00.123Z"},
+	}
+	for _, test := range tests {
+		if got := FormatTimestamp(1768132800123, test.format); got != test.want {
+			t.Errorf("%v: FormatTimestamp(1768132800123) = %q, want %q", test.format, got, test.want)
+		}
+	}
+
+	e := Entry{Desc: "Network Up", Type: "service", Start: 1768132800123, Value: "wlan0"}
+	want := "Network Up,service,2026-01-11T12:00:00.123Z,2026-01-11T12:00:00.123Z,wlan0,\n"
+	var b bytes.Buffer
+	NewStateWithOptions(&b, false, WriterOptions{TimestampFormat: ISO8601}).PrintInstantEvent(e)
+	if got := b.String(); got != want {
+		t.Errorf("PrintInstantEvent() wrote %q, want %q", got, want)
+	}
+	b.Reset()
+	if err := WriteEntriesWithOptions(&b, []Entry{e}, false, WriterOptions{TimestampFormat: ISO8601}); err != nil {
+		t.Fatalf("WriteEntriesWithOptions() unexpected error: %v", err)
+	}
+	if got := b.String(); got != want {
+		t.Errorf("WriteEntriesWithOptions() wrote %q, want %q", got, want)
+	}
+
+	// Writers created without options are unaffected, so the Historian frontend always gets ms.
+	b.Reset()
+	NewState(&b, false).PrintInstantEvent(e)
+	if got, want := b.String(), "Network Up,service,1768132800123,1768132800123,wlan0,\n"; got != want {
+		t.Errorf("NewState().PrintInstantEvent() wrote %q, want %q", got, want)
+	}
+}
+
+// TestParseTimestamp tests that times written in any format are read back as ms.
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "1768132800123", want: 1768132800123},
+		{in: "1768132800.123", want: 1768132800123},
+		{in: "1768132800.5", want: 1768132800500},
+		{in: "-1.250", want: -1250},
+		{in: "2026-01-11T12:00:00.123Z", want: 1768132800123},
+		{in: "1768132800.", wantErr: true},
+		{in: "1768132800.1234", wantErr: true},
+		{in: "2026-01-11T12:00", wantErr: true},
+		{in: "abc", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := ParseTimestamp(test.in)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseTimestamp(%q) error = %v, want error: %v", test.in, err, test.wantErr)
+			continue
+		}
+		if !test.wantErr && got != test.want {
+			t.Errorf("ParseTimestamp(%q) = %d, want %d", test.in, got, test.want)
+		}
+	}
+}
+
+// TestGroupByType tests that entries are grouped by type, keeping their order within each group.
+func TestGroupByType(t *testing.T) {
+	entries := []Entry{
+		{Desc: "CPU running", Type: "bool", Start: 1000, End: 2000, Value: "true"},
+		{Desc: "Network Up", Type: "service", Start: 1500, Value: "wlan0"},
+		{Desc: "Screen", Type: "bool", Start: 3000, End: 4000, Value: "true"},
+		{Desc: "Network Down", Type: "service", Start: 3500, Value: "wlan0"},
+		{Desc: "Audio output", Type: "string", Start: 5000, End: 6000, Value: "bt"},
+	}
+	want := map[string][]Entry{
+		"bool":    {entries[0], entries[2]},
+		"service": {entries[1], entries[3]},
+		"string":  {entries[4]},
+	}
+	if got := GroupByType(entries); !reflect.DeepEqual(got, want) {
+		t.Errorf("GroupByType(%v) = %v, want %v", entries, got, want)
+	}
+	if got := GroupByType(nil); len(got) != 0 {
+		t.Errorf("GroupByType(nil) = %v, want empty", got)
+	}
+}
+
+// TestGroupByMetric tests that entries are grouped by metric, keeping their order within each group.
+func TestGroupByMetric(t *testing.T) {
+	entries := []Entry{
+		{Desc: "CPU running", Type: "bool", Start: 1000, End: 2000, Value: "true"},
+		{Desc: "Screen", Type: "bool", Start: 1500, End: 2500, Value: "true"},
+		{Desc: "CPU running", Type: "bool", Start: 3000, End: 4000, Value: "true"},
+	}
+	want := map[string][]Entry{
+		"CPU running": {entries[0], entries[2]},
+		"Screen":      {entries[1]},
+	}
+	if got := GroupByMetric(entries); !reflect.DeepEqual(got, want) {
+		t.Errorf("GroupByMetric(%v) = %v, want %v", entries, got, want)
+	}
+}
@@ -20,7 +20,6 @@ import (
 	"errors"
 	"fmt"
 	"sort"
-	"strconv"
 	"strings"
 
 	"github.com/google/battery-historian/checkinutil"
@@ -83,16 +82,16 @@ func ExtractEvents(csvInput string, metrics []string) (map[string][]Event, []err
 }
 
 // eventFromRecord parses the parts and either returns an event if in the correct format, else an error.
-// Parts expected are desc,metricType,start,end,value,opt.
+// Parts expected are desc,metricType,start,end,value,opt. The start and end times may be in any TimestampFormat.
 func eventFromRecord(parts []string) (Event, error) {
 	if len(parts) != 6 {
 		return Event{}, fmt.Errorf("non matching %v, len was %v", parts, len(parts))
 	}
-	start, err := strconv.ParseInt(parts[2], 10, 64)
+	start, err := ParseTimestamp(parts[2])
 	if err != nil {
 		return Event{}, err
 	}
-	end, err := strconv.ParseInt(parts[3], 10, 64)
+	end, err := ParseTimestamp(parts[3])
 	if err != nil {
 		return Event{}, err
 	}
@@ -123,6 +123,32 @@ func TestExtractEvents(t *testing.T) {
 				},
 			},
 		},
+		{
+			desc: "Times not in ms",
+			input: []string{
+				FileHeader,
+				"Charging status,string,1768132800.123,1768132801.5,c,",
+				"Network Up,service,2026-01-11T12:00:02.000Z,2026-01-11T12:00:02.000Z,wlan0,",
+			},
+			wantEvents: map[string][]Event{
+				"Charging status": {
+					{
+						Type:  "string",
+						Start: 1768132800123,
+						End:   1768132801500,
+						Value: "c",
+					},
+				},
+				"Network Up": {
+					{
+						Type:  "service",
+						Start: 1768132802000,
+						End:   1768132802000,
+						Value: "wlan0",
+					},
+				},
+			},
+		},
 		{
 			desc: "Errors in parsing",
 			input: []string{
@@ -0,0 +1,115 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dumpsys
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/battery-historian/historianutils"
+)
+
+var (
+	// alarmServiceRE is a regular expression that matches the start of the alarm service dump.
+	alarmServiceRE = regexp.MustCompile(`^DUMP OF SERVICE alarm:`)
+
+	// alarmStatsStartRE is a regular expression that matches the start of the alarm stats section of the alarm service dump.
+	// e.g. "  Alarm Stats:"
+	alarmStatsStartRE = regexp.MustCompile(`^\s*Alarm Stats:`)
+
+	// alarmPackageRE is a regular expression that matches the per package row of the alarm stats section.
+	// The UID prefix is not present in older dumps.
+	// e.g. "  u0a55:com.google.android.gms +2m3s4ms running, 123 wakeups:"
+	alarmPackageRE = regexp.MustCompile(`^\s*(?:(?P<uid>[^:\s]+):)?(?P<package>[^:\s]+)\s+\+(?P<running>\S+)\s+running,\s+(?P<wakeups>\d+)\s+wakeups:`)
+
+	// alarmCountRE is a regular expression that matches a per alarm row under a package row.
+	// e.g. "    +1s2ms 45 wakes 67 alarms, last -1h2m: *walarm*:com.google.android.gms.SYNC"
+	alarmCountRE = regexp.MustCompile(`^\s*\+\S+\s+(?P<wakes>\d+)\s+wakes\s+(?P<alarms>\d+)\s+alarms`)
+)
+
+// AlarmStats holds the alarm statistics of a package from the alarm service dump.
+type AlarmStats struct {
+	// UID is the UID as printed in the dump, e.g. "u0a55", or empty if the dump doesn't include it.
+	UID     string
+	Package string
+	// Running is the total time the package's alarms were running.
+	Running time.Duration
+	Wakeups int
+	// Alarms is the total number of alarms, summed over the package's alarm rows.
+	Alarms int
+}
+
+// ParseAlarmStats extracts the per package alarm statistics from the "Alarm Stats" section of the
+// alarm service dump. Packages are returned in the order they appear in the dump.
+// Errors encountered during parsing will be collected into an errors slice and will continue parsing remaining rows.
+func ParseAlarmStats(f string) ([]AlarmStats, []error) {
+	var stats []AlarmStats
+	var errs []error
+	inService, inSection := false, false
+	indent := 0
+	for _, line := range strings.Split(f, "\n") {
+		if strings.HasPrefix(line, "DUMP OF SERVICE") || strings.HasPrefix(line, "------") {
+			if inSection {
+				break
+			}
+			inService = alarmServiceRE.MatchString(line)
+			continue
+		}
+		if !inService {
+			continue
+		}
+		if !inSection {
+			if alarmStatsStartRE.MatchString(line) {
+				inSection = true
+				indent = len(line) - len(strings.TrimLeft(line, " "))
+			}
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if len(line)-len(strings.TrimLeft(line, " ")) < indent {
+			// A less indented line starts a new section.
+			break
+		}
+		if m, result := historianutils.SubexpNames(alarmPackageRE, line); m {
+			s := AlarmStats{
+				UID:     result["uid"],
+				Package: result["package"],
+			}
+			ms, err := historianutils.ParseDurationWithDays(result["running"])
+			if err != nil {
+				errs = append(errs, fmt.Errorf("could not parse running time %q for package %s: %v", result["running"], s.Package, err))
+			}
+			s.Running = time.Duration(ms) * time.Millisecond
+			// The regular expression ensures this is a number.
+			s.Wakeups, _ = strconv.Atoi(result["wakeups"])
+			stats = append(stats, s)
+			continue
+		}
+		if m, result := historianutils.SubexpNames(alarmCountRE, line); m {
+			if len(stats) == 0 {
+				errs = append(errs, fmt.Errorf("alarm row found before any package row: %q", line))
+				continue
+			}
+			n, _ := strconv.Atoi(result["alarms"])
+			stats[len(stats)-1].Alarms += n
+		}
+	}
+	return stats, errs
+}
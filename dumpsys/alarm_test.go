@@ -0,0 +1,79 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dumpsys
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParseAlarmStats tests the extraction of per package alarm statistics from the alarm service dump.
+func TestParseAlarmStats(t *testing.T) {
+	tests := []struct {
+		desc  string
+		input []string
+		want  []AlarmStats
+	}{
+		{
+			desc: "Multiple package rows",
+			input: []string{
+				`DUMP OF SERVICE alarm:`,
+				`Current Alarm Manager state:`,
+				`  Pending alarm batches: 3`,
+				``,
+				`  Alarm Stats:`,
+				`  u0a55:com.google.android.gms +2m3s4ms running, 123 wakeups:`,
+				`    +1s2ms 45 wakes 67 alarms, last -1h2m: *walarm*:com.google.android.gms.SYNC`,
+				`    +500ms 3 wakes 10 alarms, last -5m: *alarm*:com.google.android.gms.GCM`,
+				`  1000:android +10s running, 5 wakeups:`,
+				`    +10s 5 wakes 5 alarms, last -2m: *alarm*:android.intent.action.TIME_TICK`,
+				`--------- 0.012s was the duration of dumpsys alarm`,
+				`DUMP OF SERVICE appops:`,
+				`  u0a99:com.example.app +1s running, 1 wakeups:`,
+			},
+			want: []AlarmStats{
+				{UID: "u0a55", Package: "com.google.android.gms", Running: 2*time.Minute + 3*time.Second + 4*time.Millisecond, Wakeups: 123, Alarms: 77},
+				{UID: "1000", Package: "android", Running: 10 * time.Second, Wakeups: 5, Alarms: 5},
+			},
+		},
+		{
+			desc: "Package rows without UIDs",
+			input: []string{
+				`DUMP OF SERVICE alarm:`,
+				`  Alarm Stats:`,
+				`  com.example.app +1m running, 2 wakeups:`,
+				`    +1m 2 wakes 4 alarms: *walarm*:com.example.app.REFRESH`,
+			},
+			want: []AlarmStats{
+				{Package: "com.example.app", Running: time.Minute, Wakeups: 2, Alarms: 4},
+			},
+		},
+		{
+			desc:  "No alarm service dump",
+			input: []string{`  u0a55:com.google.android.gms +2m3s4ms running, 123 wakeups:`},
+		},
+	}
+	for _, test := range tests {
+		got, errs := ParseAlarmStats(strings.Join(test.input, "\n"))
+		if len(errs) > 0 {
+			t.Errorf("%v: ParseAlarmStats(%v) got unexpected errors: %v", test.desc, test.input, errs)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: ParseAlarmStats(%v) = %+v, want %+v", test.desc, test.input, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dumpsys
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/battery-historian/historianutils"
+)
+
+var (
+	// deviceIdleServiceRE is a regular expression that matches the start of the deviceidle dump.
+	deviceIdleServiceRE = regexp.MustCompile(`^DUMP OF SERVICE deviceidle:`)
+
+	// deviceIdleHeadingRE is a regular expression that matches the heading of a list in the deviceidle dump.
+	// e.g. "  Whitelist user apps:"
+	deviceIdleHeadingRE = regexp.MustCompile(`^(?P<heading>[^=]+):$`)
+
+	// tempWhitelistRE is a regular expression that matches a row of the temp whitelist schedule, giving
+	// the time until the app is removed from the whitelist, and the reason it was added.
+	// e.g. "    UID=10012: +9s983ms - GCM"
+	tempWhitelistRE = regexp.MustCompile(`^\s*UID=(?P<uid>\d+):\s+(?P<remaining>[+-]?\S+)\s+-\s+(?P<reason>.*)$`)
+)
+
+// permanentWhitelistHeadings are the headings of the lists of apps that are exempt from doze.
+// Apps in the "Whitelist (except idle)" lists are still restricted in doze, so aren't included.
+var permanentWhitelistHeadings = map[string]bool{
+	"Whitelist system apps": true,
+	"Whitelist user apps":   true,
+}
+
+// DeviceIdleWhitelist holds the apps that are exempt from doze.
+type DeviceIdleWhitelist struct {
+	// Permanent lists the packages that are always exempt from doze, in the order they appear in the dump.
+	Permanent []string
+	// Temp lists the apps that have been temporarily exempted from doze, e.g. to handle a high priority message.
+	Temp []TempWhitelistEntry
+}
+
+// TempWhitelistEntry is an app that has been temporarily exempted from doze.
+type TempWhitelistEntry struct {
+	UID string
+	// Remaining is the time from the dump until the app is removed from the whitelist.
+	Remaining time.Duration
+	// Reason is the reason the app was added, e.g. "GCM".
+	Reason string
+}
+
+// ParseDeviceIdleWhitelist extracts the permanent and temporary doze whitelists from the deviceidle dump.
+// Errors encountered during parsing will be collected into an errors slice and will continue parsing remaining rows.
+func ParseDeviceIdleWhitelist(f string) (DeviceIdleWhitelist, []error) {
+	var wl DeviceIdleWhitelist
+	var errs []error
+	inService := false
+	heading := ""
+	for _, line := range strings.Split(f, "\n") {
+		if strings.HasPrefix(line, "DUMP OF SERVICE") {
+			if inService {
+				break
+			}
+			inService = deviceIdleServiceRE.MatchString(line)
+			continue
+		}
+		if !inService {
+			continue
+		}
+		row := strings.TrimSpace(line)
+		if row == "" {
+			continue
+		}
+		if len(line)-len(strings.TrimLeft(line, " ")) <= 2 {
+			// Rows that aren't indented under a heading end the previous list, and may start a new one.
+			heading = ""
+			if m, result := historianutils.SubexpNames(deviceIdleHeadingRE, line); m {
+				heading = result["heading"]
+			}
+			continue
+		}
+		switch {
+		case permanentWhitelistHeadings[heading]:
+			wl.Permanent = append(wl.Permanent, row)
+		case heading == "Temp whitelist schedule":
+			m, result := historianutils.SubexpNames(tempWhitelistRE, line)
+			if !m {
+				errs = append(errs, fmt.Errorf("could not parse temp whitelist row %q", row))
+				continue
+			}
+			ms, err := historianutils.ParseDurationWithDays(strings.TrimPrefix(result["remaining"], "+"))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("could not parse remaining time %q for UID %s: %v", result["remaining"], result["uid"], err))
+			}
+			wl.Temp = append(wl.Temp, TempWhitelistEntry{
+				UID:       result["uid"],
+				Remaining: time.Duration(ms) * time.Millisecond,
+				Reason:    result["reason"],
+			})
+		}
+	}
+	return wl, errs
+}
@@ -0,0 +1,76 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dumpsys
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParseDeviceIdleWhitelist tests the extraction of the permanent and temporary doze whitelists.
+func TestParseDeviceIdleWhitelist(t *testing.T) {
+	tests := []struct {
+		desc  string
+		input []string
+		want  DeviceIdleWhitelist
+	}{
+		{
+			desc: "Permanent and temporary whitelists",
+			input: []string{
+				`DUMP OF SERVICE deviceidle:`,
+				`  Settings:`,
+				`    light_after_inactive_to=+3m0s0ms`,
+				`  Whitelist (except idle) system apps:`,
+				`    com.android.providers.downloads`,
+				`  Whitelist system apps:`,
+				`    com.android.shell`,
+				`    com.google.android.gms`,
+				`  Whitelist user apps:`,
+				`    com.example.messenger`,
+				`  Whitelist (except idle) all app ids:`,
+				`    1000`,
+				`  Temp whitelist schedule:`,
+				`    UID=10012: +9s983ms - GCM`,
+				`    UID=10099: +1m0s0ms - broadcast:com.example.alarm`,
+				`  mLightEnabled=true  mDeepEnabled=true`,
+				`DUMP OF SERVICE diskstats:`,
+				`  Whitelist user apps:`,
+				`    com.example.ignored`,
+			},
+			want: DeviceIdleWhitelist{
+				Permanent: []string{"com.android.shell", "com.google.android.gms", "com.example.messenger"},
+				Temp: []TempWhitelistEntry{
+					{UID: "10012", Remaining: 9*time.Second + 983*time.Millisecond, Reason: "GCM"},
+					{UID: "10099", Remaining: time.Minute, Reason: "broadcast:com.example.alarm"},
+				},
+			},
+		},
+		{
+			desc:  "No deviceidle dump",
+			input: []string{`  Whitelist user apps:`, `    com.example.messenger`},
+		},
+	}
+	for _, test := range tests {
+		got, errs := ParseDeviceIdleWhitelist(strings.Join(test.input, "\n"))
+		if len(errs) > 0 {
+			t.Errorf("%v: ParseDeviceIdleWhitelist(%v) got unexpected errors: %v", test.desc, test.input, errs)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: ParseDeviceIdleWhitelist(%v) = %+v, want %+v", test.desc, test.input, got, test.want)
+		}
+	}
+}
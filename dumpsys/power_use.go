@@ -0,0 +1,73 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dumpsys parses the human readable service dumps found in bugreport files.
+package dumpsys
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/battery-historian/historianutils"
+)
+
+var (
+	// powerUseStartRE is a regular expression that matches the start of the estimated power use section of the batterystats dump.
+	// e.g. "  Estimated power use (mAh):"
+	powerUseStartRE = regexp.MustCompile(`^\s*Estimated power use \(mAh\):`)
+
+	// powerUseUIDRE is a regular expression that matches a per UID row of the estimated power use section.
+	// e.g. "    Uid u0a55: 12.3 ( cpu=10.0 wake=1.2 )"
+	powerUseUIDRE = regexp.MustCompile(`^\s*Uid\s+(?P<uid>[^:\s]+):\s+(?P<mAh>[\d.]+)`)
+)
+
+// EstimatedPowerUse extracts the estimated power use in mAh of each UID from the first
+// "Estimated power use" section of the batterystats dump.
+// The map is keyed by the UID as printed in the dump, e.g. "u0a55" or "1000".
+// Errors encountered during parsing will be collected into an errors slice and will continue parsing remaining rows.
+func EstimatedPowerUse(f string) (map[string]float64, []error) {
+	usage := make(map[string]float64)
+	var errs []error
+	inSection := false
+	for _, line := range strings.Split(f, "\n") {
+		if powerUseStartRE.MatchString(line) {
+			if inSection {
+				// Only the first section (since last charge) is used.
+				break
+			}
+			inSection = true
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			// A blank line terminates the section.
+			break
+		}
+		m, result := historianutils.SubexpNames(powerUseUIDRE, line)
+		if !m {
+			continue
+		}
+		mAh, err := strconv.ParseFloat(result["mAh"], 64)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not parse power use %q for uid %s: %v", result["mAh"], result["uid"], err))
+			continue
+		}
+		usage[result["uid"]] += mAh
+	}
+	return usage, errs
+}
@@ -0,0 +1,75 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dumpsys
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestEstimatedPowerUse tests the extraction of per UID estimated power use from the batterystats dump.
+func TestEstimatedPowerUse(t *testing.T) {
+	tests := []struct {
+		desc  string
+		input []string
+		want  map[string]float64
+	}{
+		{
+			desc: "Multiple UID rows",
+			input: []string{
+				`  Estimated power use (mAh):`,
+				`    Capacity: 3000, Computed drain: 120, actual drain: 110-130`,
+				`    Screen: 45.2`,
+				`    Uid u0a55: 12.3 ( cpu=10.0 wake=1.2 )`,
+				`    Uid 1000: 45.6 ( cpu=40.1 )`,
+				`    Cell standby: 3.1`,
+				``,
+				`  All kernel wake locks:`,
+				`    Uid u0a99: 99.9`,
+			},
+			want: map[string]float64{
+				"u0a55": 12.3,
+				"1000":  45.6,
+			},
+		},
+		{
+			desc: "Only the first section is used",
+			input: []string{
+				`  Estimated power use (mAh):`,
+				`    Uid u0a10: 1.5`,
+				`  Estimated power use (mAh):`,
+				`    Uid u0a10: 7.5`,
+			},
+			want: map[string]float64{
+				"u0a10": 1.5,
+			},
+		},
+		{
+			desc:  "No power use section",
+			input: []string{`    Uid u0a10: 1.5`},
+			want:  map[string]float64{},
+		},
+	}
+	for _, test := range tests {
+		got, errs := EstimatedPowerUse(strings.Join(test.input, "\n"))
+		if len(errs) > 0 {
+			t.Errorf("%v: EstimatedPowerUse(%v) got unexpected errors: %v", test.desc, test.input, errs)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: EstimatedPowerUse(%v) = %v, want %v", test.desc, test.input, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dumpsys
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/battery-historian/historianutils"
+)
+
+var (
+	// sensorServiceRE is a regular expression that matches the start of the sensorservice dump.
+	sensorServiceRE = regexp.MustCompile(`^DUMP OF SERVICE sensorservice:`)
+
+	// sensorRegistrationsStartRE is a regular expression that matches the start of the registrations
+	// section of the sensorservice dump.
+	sensorRegistrationsStartRE = regexp.MustCompile(`^\s*Previous Registrations:`)
+
+	// sensorRegistrationRE is a regular expression that matches a sensor being activated or de-activated
+	// by an app. The periods are only logged when the sensor is activated.
+	// e.g. "12:34:56 + 0x0000000b pid= 1234 uid=10012 package=com.example.app samplingPeriod=20000us batchingPeriod=0us"
+	// or "12:35:10 - 0x0000000b pid= 1234 uid=10012 package=com.example.app"
+	sensorRegistrationRE = regexp.MustCompile(`^\s*\d+:\d+:\d+\s+(?P<op>[+-])\s+(?P<handle>0x[0-9a-fA-F]+)\s+pid=\s*\d+\s+uid=\s*(?P<uid>\d+)\s+package=(?P<package>\S+)` +
+		`(?:\s+samplingPeriod=(?P<sampling>\d+)us\s+batchingPeriod=(?P<batching>\d+)us)?`)
+)
+
+// SensorSubscription is a sensor registration held by an app at the time of the sensorservice dump.
+type SensorSubscription struct {
+	UID     string
+	Package string
+	// Handle identifies the sensor, e.g. "0x0000000b".
+	Handle         string
+	SamplingPeriod time.Duration
+	// BatchingPeriod is the maximum time events can be delayed before being reported to the app.
+	BatchingPeriod time.Duration
+}
+
+// RateHz returns the rate the sensor is sampled at for the subscription, or 0 if the sampling period is unknown.
+func (s SensorSubscription) RateHz() float64 {
+	if s.SamplingPeriod <= 0 {
+		return 0
+	}
+	return float64(time.Second) / float64(s.SamplingPeriod)
+}
+
+// ParseSensorRegistrations extracts the sensor subscriptions that were active at the time of the
+// sensorservice dump, from its "Previous Registrations" section. Since the section only lists the most
+// recent activations and de-activations, subscriptions made before the oldest listed one are missed.
+// Subscriptions are sorted by package, then by sensor handle.
+// Errors encountered during parsing will be collected into an errors slice and will continue parsing remaining rows.
+func ParseSensorRegistrations(f string) ([]SensorSubscription, []error) {
+	var rows []map[string]string
+	var errs []error
+	inService, inSection := false, false
+	for _, line := range strings.Split(f, "\n") {
+		if strings.HasPrefix(line, "DUMP OF SERVICE") {
+			if inService {
+				break
+			}
+			inService = sensorServiceRE.MatchString(line)
+			continue
+		}
+		if !inService {
+			continue
+		}
+		if !inSection {
+			inSection = sensorRegistrationsStartRE.MatchString(line)
+			continue
+		}
+		m, result := historianutils.SubexpNames(sensorRegistrationRE, line)
+		if !m {
+			// The section ends at the first row that isn't a registration.
+			break
+		}
+		rows = append(rows, result)
+	}
+
+	type key struct {
+		uid, pkg, handle string
+	}
+	active := make(map[key]SensorSubscription)
+	// The registrations are listed most recent first, so replay them in reverse.
+	for i := len(rows) - 1; i >= 0; i-- {
+		r := rows[i]
+		k := key{r["uid"], r["package"], strings.ToLower(r["handle"])}
+		if r["op"] == "-" {
+			delete(active, k)
+			continue
+		}
+		s := SensorSubscription{UID: k.uid, Package: k.pkg, Handle: k.handle}
+		for _, p := range []struct {
+			name string
+			d    *time.Duration
+		}{{"sampling", &s.SamplingPeriod}, {"batching", &s.BatchingPeriod}} {
+			if r[p.name] == "" {
+				continue
+			}
+			us, err := strconv.ParseInt(r[p.name], 10, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("could not parse %s period %q for package %s: %v", p.name, r[p.name], k.pkg, err))
+				continue
+			}
+			*p.d = time.Duration(us) * time.Microsecond
+		}
+		active[k] = s
+	}
+
+	var subs []SensorSubscription
+	for _, s := range active {
+		subs = append(subs, s)
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		if subs[i].Package != subs[j].Package {
+			return subs[i].Package < subs[j].Package
+		}
+		if subs[i].Handle != subs[j].Handle {
+			return subs[i].Handle < subs[j].Handle
+		}
+		return subs[i].UID < subs[j].UID
+	})
+	return subs, errs
+}
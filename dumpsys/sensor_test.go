@@ -0,0 +1,83 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dumpsys
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParseSensorRegistrations tests the extraction of active sensor subscriptions from the sensorservice dump.
+func TestParseSensorRegistrations(t *testing.T) {
+	tests := []struct {
+		desc  string
+		input []string
+		want  []SensorSubscription
+	}{
+		{
+			desc: "Active and de-activated registrations",
+			input: []string{
+				`DUMP OF SERVICE sensorservice:`,
+				`Sensor Device:`,
+				`Total 2 h/w sensors, 2 running 0 disabled clients:`,
+				`Previous Registrations:`,
+				`12:40:00 + 0x0000000b pid= 2345 uid=10099 package=com.example.fitness samplingPeriod=5000us batchingPeriod=0us`,
+				`12:35:10 - 0x00000001 pid= 1234 uid=10012 package=com.google.android.gms`,
+				`12:34:56 + 0x0000000b pid= 1234 uid=10012 package=com.google.android.gms samplingPeriod=200000us batchingPeriod=10000000us`,
+				`12:30:00 + 0x00000001 pid= 1234 uid=10012 package=com.google.android.gms samplingPeriod=20000us batchingPeriod=0us`,
+				``,
+				`12:00:00 + 0x00000002 pid=   99 uid=10001 package=com.example.ignored samplingPeriod=1000us batchingPeriod=0us`,
+				`DUMP OF SERVICE statusbar:`,
+			},
+			want: []SensorSubscription{
+				{UID: "10099", Package: "com.example.fitness", Handle: "0x0000000b", SamplingPeriod: 5 * time.Millisecond},
+				{UID: "10012", Package: "com.google.android.gms", Handle: "0x0000000b", SamplingPeriod: 200 * time.Millisecond, BatchingPeriod: 10 * time.Second},
+			},
+		},
+		{
+			desc:  "No sensorservice dump",
+			input: []string{`12:30:00 + 0x00000001 pid= 1234 uid=10012 package=com.google.android.gms samplingPeriod=20000us batchingPeriod=0us`},
+		},
+	}
+	for _, test := range tests {
+		got, errs := ParseSensorRegistrations(strings.Join(test.input, "\n"))
+		if len(errs) > 0 {
+			t.Errorf("%v: ParseSensorRegistrations(%v) got unexpected errors: %v", test.desc, test.input, errs)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: ParseSensorRegistrations(%v) = %+v, want %+v", test.desc, test.input, got, test.want)
+		}
+	}
+}
+
+// TestSensorSubscriptionRateHz tests the conversion of sampling periods to rates.
+func TestSensorSubscriptionRateHz(t *testing.T) {
+	tests := []struct {
+		period time.Duration
+		want   float64
+	}{
+		{5 * time.Millisecond, 200},
+		{time.Second, 1},
+		{0, 0},
+	}
+	for _, test := range tests {
+		s := SensorSubscription{SamplingPeriod: test.period}
+		if got := s.RateHz(); got != test.want {
+			t.Errorf("SensorSubscription{SamplingPeriod: %v}.RateHz() = %v, want %v", test.period, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dumpsys
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/battery-historian/historianutils"
+)
+
+var (
+	// thermalServiceRE is a regular expression that matches the start of the thermalservice dump.
+	thermalServiceRE = regexp.MustCompile(`^DUMP OF SERVICE thermalservice:`)
+
+	// thermalCurrentStartRE is a regular expression that matches the start of the current temperatures
+	// section of the thermalservice dump.
+	thermalCurrentStartRE = regexp.MustCompile(`^\s*Current temperatures from HAL:`)
+
+	// thermalTemperatureRE is a regular expression that matches a zone temperature row.
+	// e.g. "	Temperature{mValue=33.5, mType=0, mName=CPU0, mStatus=0}"
+	thermalTemperatureRE = regexp.MustCompile(`^\s*Temperature\{mValue=(?P<value>[^,]+),.*\bmName=(?P<name>[^,}]+)`)
+)
+
+// ThermalZoneTemperatures extracts the current temperature in degrees Celsius of each thermal zone,
+// e.g. "CPU0", "skin" or "battery", from the thermalservice dump.
+// Errors encountered during parsing will be collected into an errors slice and will continue parsing remaining rows.
+func ThermalZoneTemperatures(f string) (map[string]float64, []error) {
+	temps := make(map[string]float64)
+	var errs []error
+	inService, inSection := false, false
+	for _, line := range strings.Split(f, "\n") {
+		if strings.HasPrefix(line, "DUMP OF SERVICE") {
+			if inService {
+				break
+			}
+			inService = thermalServiceRE.MatchString(line)
+			continue
+		}
+		if !inService {
+			continue
+		}
+		if !inSection {
+			inSection = thermalCurrentStartRE.MatchString(line)
+			continue
+		}
+		m, result := historianutils.SubexpNames(thermalTemperatureRE, line)
+		if !m {
+			// The section ends at the first row that isn't a temperature.
+			break
+		}
+		v, err := strconv.ParseFloat(result["value"], 64)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not parse temperature %q for zone %s: %v", result["value"], result["name"], err))
+			continue
+		}
+		temps[result["name"]] = v
+	}
+	return temps, errs
+}
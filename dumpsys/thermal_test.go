@@ -0,0 +1,68 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dumpsys
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestThermalZoneTemperatures tests the extraction of per zone temperatures from the thermalservice dump.
+func TestThermalZoneTemperatures(t *testing.T) {
+	tests := []struct {
+		desc  string
+		input []string
+		want  map[string]float64
+	}{
+		{
+			desc: "Multiple zones",
+			input: []string{
+				`DUMP OF SERVICE thermalservice:`,
+				`IsStatusOverride: false`,
+				`ThermalEventListeners:`,
+				`	callbacks: 2`,
+				`Current temperatures from HAL:`,
+				`	Temperature{mValue=41.2, mType=0, mName=CPU0, mStatus=1}`,
+				`	Temperature{mValue=33.5, mType=3, mName=skin, mStatus=0}`,
+				`	Temperature{mValue=30.1, mType=2, mName=battery, mStatus=0}`,
+				`Current cooling devices from HAL:`,
+				`	CoolingDevice{mValue=0, mType=2, mName=cpu0}`,
+				`DUMP OF SERVICE thermalservice2:`,
+				`Current temperatures from HAL:`,
+				`	Temperature{mValue=99.9, mType=0, mName=CPU0, mStatus=6}`,
+			},
+			want: map[string]float64{
+				"CPU0":    41.2,
+				"skin":    33.5,
+				"battery": 30.1,
+			},
+		},
+		{
+			desc:  "No thermalservice dump",
+			input: []string{`	Temperature{mValue=41.2, mType=0, mName=CPU0, mStatus=1}`},
+			want:  map[string]float64{},
+		},
+	}
+	for _, test := range tests {
+		got, errs := ThermalZoneTemperatures(strings.Join(test.input, "\n"))
+		if len(errs) > 0 {
+			t.Errorf("%v: ThermalZoneTemperatures(%v) got unexpected errors: %v", test.desc, test.input, errs)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: ThermalZoneTemperatures(%v) = %v, want %v", test.desc, test.input, got, test.want)
+		}
+	}
+}
@@ -160,7 +160,7 @@ historian.metrics.Csv = {
   BACKGROUND_COMPILATION: 'dex2oat',
   BATTERY_TEST_UTIL: 'BatteryTestUtil',
   BLUETOOTH_SCAN: 'Bluetooth Scan',
-  CHOREOGRAPHER_SKIPPED: 'Choreographer (skipped frames)',
+  CHOREOGRAPHER_SKIPPED: 'Jank',
   CRASHES: 'Crashes',
   GC_PAUSE_BACKGROUND_PARTIAL: 'GC Pause - Background (partial)',
   GC_PAUSE_BACKGROUND_STICKY: 'GC Pause - Background (sticky)',
@@ -218,7 +218,7 @@ historian.metrics.Csv = {
   AM_PROVIDER_LOST_PROCESS: 'am_provider_lost_process',
   AM_PROCESS_START_TIMEOUT: 'am_process_start_timeout',
   AM_CRASH: 'am_crash',
-  AM_WTF: 'am_wtf',
+  AM_WTF: 'WTF',
   AM_SWITCH_USER: 'am_switch_user',
   AM_ACTIVITY_FULLY_DRAWN_TIME: 'am_activity_fully_drawn_time',
   AM_SET_RESUMED_ACTIVITY: 'am_set_resumed_activity',
@@ -19,9 +19,11 @@ package parseutils
 import (
 	"errors"
 	"fmt"
+	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/battery-historian/csv"
@@ -34,23 +36,67 @@ import (
 
 // BatteryHistoryV2Entry represents a parsed line from Battery History Format 2
 type BatteryHistoryV2Entry struct {
-	Timestamp           time.Time
-	TimestampMs         int64
-	BatteryPercent      int32
-	Voltage             int32
-	Temperature         int32
-	ChargeMicroAh       int64
-	Status              string
-	Health              string
-	PlugType            string
-	DataConn            string
-	PhoneSignalStrength string
-	WiFiSignalStrength  int32
-	WiFiSupplicantState string
-	DeviceIdleMode      string
-	States              map[string]bool  // e.g., "+running", "-wifi"
-	WakeReasons         map[string]bool  // e.g., "wlan_wake", "rtc_alarm"
-	RailCharges         map[string]int64 // e.g., "modemRailChargemAh"
+	Timestamp              time.Time
+	TimestampMs            int64
+	BatteryPercent         int32 // battery level, e.g. 075
+	Voltage                int32
+	Temperature            int32
+	ChargeMicroAh          int64
+	Status                 string
+	Health                 string
+	PlugType               string
+	DataConn               string
+	PhoneSignalStrength    string
+	PhoneState             string // phone radio state, e.g. "in", "out", "emergency" or "off"
+	WiFiSignalStrength     int32
+	WiFiSupplicantState    string
+	DeviceIdleMode         string
+	NRState                string          // 5G NR connection substate, e.g. "connected"
+	CurrentNowMicroA       int32           // Instantaneous battery current, negative while discharging
+	SetFields              map[string]bool // keys present on the line, e.g. "volt"
+	WiFiRSSI               int32           // dBm, only set when the line carries an RSSI
+	ScreenDoze             bool            // always-on display
+	CycleCount             int32
+	CameraOn               bool
+	FlashlightOn           bool
+	WakeLocks              []WakeLockTransition // e.g., +wake_lock=1000:"*alarm*"
+	ChargeTimeRemainingSec int32                // estimated time until fully charged, only reported while charging
+	VibrationActive        bool                 // haptic feedback
+	UnknownKeys            map[string]string    // unrecognized key=value pairs, keyed by key
+	GPSSignalQuality       string               // e.g. "good", "poor"
+	EthernetOn             bool
+	CPUCoreRunning         map[int]bool // keyed by core, e.g. +cpu3_running
+	BatterySaver           bool
+	InCall                 bool // active voice call
+	StepDetectorActive     bool
+	AudioOutputRoute       string // e.g. "speaker", "bt", "headset"
+	BatteryMfgDate         string // OEM specific, e.g. "2023-04-12"
+	BatterySerial          string // OEM specific
+	TimeChanged            bool   // the line carries a RESET:TIME or TIME marker
+	UsbDataActive          bool   // USB connected for data rather than charge only
+	NFCOn                  bool
+	WiFiMulticast          bool // multicast lock held
+	BluetoothOn            bool // classic Bluetooth, separate from BLE scanning
+	BLEScanning            bool
+	ChargeFull             int64   // full charge capacity in mAh, reported as charge_full
+	SoC                    float64 // derived by ParseHistoryV2Block, zero before a full charge anchor is seen
+	AirplaneMode           bool    // +airplane_mode, or derived by ParseHistoryV2Block from phone_state=off
+	BLEAdvertising         bool
+	VideoOn                bool
+	VideoDecoder           string           // "hw" or "sw", e.g. +video=hw,1080p
+	VideoResolution        string           // e.g. "1080p"
+	LocationProvider       string           // "gps", "fused" or "network", e.g. location_provider=fused or +fused_location
+	RadioActivity          RadioActivity    // derived by ParseHistoryV2Block
+	BLEScanMode            string           // e.g. "opportunistic", "balanced", "low_latency", reported as ble_scan_mode
+	TopApp                 string           // package moved to the foreground, e.g. +top=u0a123:"com.android.camera"
+	TopAppLeft             string           // package that left the foreground, e.g. -top=u0a123:"com.android.camera"
+	WiFiHotspot            bool             // tethering or hotspot enabled
+	ForegroundStarted      []string         // packages that entered the foreground, e.g. +fg=u0a123:"com.example.app"
+	ForegroundStopped      []string         // packages that left the foreground, e.g. -fg=u0a123:"com.example.app"
+	States                 map[string]bool  // e.g., "+running", "-wifi"
+	PlatformStates         map[string]bool  // platform specific states, e.g. "+body_sensor" on wear
+	WakeReasons            map[string]bool  // e.g., "wlan_wake", "rtc_alarm"
+	RailCharges            map[string]int64 // e.g., "modemRailChargemAh"
 }
 
 var (
@@ -64,10 +110,161 @@ var (
 	// Pattern for state transitions (+state or -state)
 	stateTransitionPattern = regexp.MustCompile(`([+-])(\w+)`)
 
+	// Pattern for wifi_signal_strength values, with an optional RSSI in dBm
+	// Example: 4 or 4(-55dBm)
+	wifiSignalPattern = regexp.MustCompile(`^(\d+)(?:\((-?\d+)dBm\))?$`)
+
+	// Pattern for wake lock transitions. The UID and tag are omitted when all wake locks are released.
+	// Example: +wake_lock=u0a231:"*alarm*" or -wake_lock
+	wakeLockPattern = regexp.MustCompile(`([+-])wake_lock(?:=([^:\s]+):"([^"]*)")?`)
+
+	// Pattern for per-core CPU running states
+	// Example: cpu3_running
+	cpuCoreRunningPattern = regexp.MustCompile(`^cpu(\d+)_running$`)
+
+	// Pattern for markers logged when the history was reset or the system time was changed
+	// Example: RESET:TIME:2026-01-11-12-00-00 or TIME:2026-01-11-12-00-00
+	timeMarkerPattern = regexp.MustCompile(`(?:^|\s)(?:RESET:)?TIME:`)
+
+	// Pattern for video transitions, with optional decoder and resolution qualifiers
+	// Example: +video, +video=hw,1080p or -video
+	videoPattern = regexp.MustCompile(`(?:^|\s)([+-])video(?:=([\w,]+))?(?:\s|$)`)
+
+	// Pattern for the top app changing, or a process entering or leaving the foreground, e.g. by
+	// running a foreground service
+	// Example: +top=u0a123:"com.android.camera" or -fg=u0a99:"com.example.app"
+	topAppPattern = regexp.MustCompile(`(?:^|\s)([+-])(top|fg)=[^:\s]+:"([^"]*)"`)
+
 	// Pattern for wake_reason=0:"reason_string"
 	wakeReasonPattern = regexp.MustCompile(`wake_reason=\d+:"([^"]+)"`)
 )
 
+// RadioActivity is the state of the mobile radio, derived from the data connection and the
+// mobile_radio state.
+type RadioActivity int
+
+const (
+	// RadioOff means there is no mobile data connection and the radio isn't active.
+	RadioOff RadioActivity = iota
+	// RadioIdle means there is a mobile data connection, e.g. data_conn=lte, but the radio isn't
+	// transmitting or receiving.
+	RadioIdle
+	// RadioActive means the radio is actively transmitting or receiving, i.e. +mobile_radio.
+	RadioActive
+)
+
+// String returns the name of the radio activity.
+func (r RadioActivity) String() string {
+	switch r {
+	case RadioOff:
+		return "off"
+	case RadioIdle:
+		return "idle"
+	case RadioActive:
+		return "active"
+	}
+	return fmt.Sprintf("RadioActivity(%d)", int(r))
+}
+
+// WakeLock identifies a wake lock by the UID of its owner and its tag.
+type WakeLock struct {
+	UID string
+	Tag string
+}
+
+// WakeLockTransition is a wake lock being acquired (+wake_lock) or released (-wake_lock).
+// A release with an empty UID and tag releases all held wake locks.
+type WakeLockTransition struct {
+	WakeLock
+	Active bool
+}
+
+// Apply applies the transition at the given time to held, which maps each held wake lock to the time
+// in ms it was acquired. A wake lock acquired again while held keeps its original acquisition time.
+// It returns the wake locks released by the transition, mapped to the time they were acquired.
+func (t WakeLockTransition) Apply(held map[WakeLock]int64, timestampMs int64) map[WakeLock]int64 {
+	released := make(map[WakeLock]int64)
+	switch {
+	case t.Active:
+		if _, ok := held[t.WakeLock]; !ok {
+			held[t.WakeLock] = timestampMs
+		}
+	case t.UID == "" && t.Tag == "":
+		for wl, start := range held {
+			released[wl] = start
+			delete(held, wl)
+		}
+	default:
+		if start, ok := held[t.WakeLock]; ok {
+			released[t.WakeLock] = start
+			delete(held, t.WakeLock)
+		}
+	}
+	return released
+}
+
+// Platform identifies the kind of device a bugreport was taken on.
+type Platform string
+
+// The platforms with platform specific history states.
+const (
+	PlatformPhone Platform = ""
+	PlatformWear  Platform = "wear"
+	PlatformTV    Platform = "tv"
+)
+
+// platformState is a history state that is only emitted on a particular platform.
+type platformState struct {
+	platform Platform
+	state    string
+	// metric is the name of the Historian track built from the state.
+	metric string
+}
+
+// platformStates lists the recognized platform specific states, in track output order.
+var platformStates = []platformState{
+	{PlatformWear, "body_sensor", "Body sensor"},
+	{PlatformTV, "hdmi", "HDMI"},
+}
+
+// ParseContext holds information about the bugreport that affects how history lines are parsed.
+type ParseContext struct {
+	// Platform selects the platform specific states that are recognized.
+	Platform Platform
+}
+
+// ParseHistoryV2LineWithContext parses a single line from Battery History Format 2, additionally
+// recording the states specific to the context's platform in PlatformStates.
+func ParseHistoryV2LineWithContext(ctx ParseContext, line string) (*BatteryHistoryV2Entry, error) {
+	entry, err := ParseHistoryV2Line(line)
+	if err != nil {
+		return nil, err
+	}
+	for _, ps := range platformStates {
+		if ps.platform != ctx.Platform {
+			continue
+		}
+		if on, ok := entry.States[ps.state]; ok {
+			entry.PlatformStates[ps.state] = on
+		}
+	}
+	return entry, nil
+}
+
+var (
+	unknownKeyHandlerMu sync.RWMutex
+	unknownKeyHandler   func(key, value string)
+)
+
+// SetUnknownKeyHandler sets a function to be called for every key=value pair on a Format 2 history
+// line that isn't recognized, e.g. to log keys added by new Android versions. Passing nil removes
+// the handler. The handler may be called concurrently when blocks are parsed concurrently.
+func SetUnknownKeyHandler(h func(key, value string)) {
+	unknownKeyHandlerMu.Lock()
+	defer unknownKeyHandlerMu.Unlock()
+	unknownKeyHandler = h
+}
+
 // ParseHistoryV2Line parses a single line from Battery History Format 2
 func ParseHistoryV2Line(line string) (*BatteryHistoryV2Entry, error) {
 	matches := historyLinePatternV2.FindStringSubmatch(strings.TrimSpace(line))
@@ -76,9 +273,11 @@ func ParseHistoryV2Line(line string) (*BatteryHistoryV2Entry, error) {
 	}
 
 	entry := &BatteryHistoryV2Entry{
-		States:      make(map[string]bool),
-		WakeReasons: make(map[string]bool),
-		RailCharges: make(map[string]int64),
+		States:         make(map[string]bool),
+		PlatformStates: make(map[string]bool),
+		WakeReasons:    make(map[string]bool),
+		RailCharges:    make(map[string]int64),
+		SetFields:      make(map[string]bool),
 	}
 
 	// Parse timestamp (e.g., "01-11 12:11:14.405")
@@ -93,12 +292,21 @@ func ParseHistoryV2Line(line string) (*BatteryHistoryV2Entry, error) {
 	} else {
 		entry.Timestamp = ts
 	}
+	entry.TimestampMs = entry.Timestamp.UnixNano() / int64(time.Millisecond)
+
+	if v, err := strconv.ParseInt(matches[3], 10, 32); err == nil {
+		entry.BatteryPercent = int32(v)
+	}
 
 	// Parse remainder of line for key=value pairs and state transitions
 	remainder := matches[5]
 	parseStateTransitionsV2(entry, remainder)
 	parseKeyValuePairsV2(entry, remainder)
 	parseWakeReasonsV2(entry, remainder)
+	parseWakeLocksV2(entry, remainder)
+	parseVideoV2(entry, remainder)
+	parseTopAppV2(entry, remainder)
+	entry.TimeChanged = timeMarkerPattern.MatchString(remainder)
 
 	return entry, nil
 }
@@ -106,9 +314,13 @@ func ParseHistoryV2Line(line string) (*BatteryHistoryV2Entry, error) {
 // parseKeyValuePairsV2 extracts all key=value pairs from the history line
 func parseKeyValuePairsV2(entry *BatteryHistoryV2Entry, line string) {
 	matches := keyValuePattern.FindAllStringSubmatch(line, -1)
+	if len(matches) > 0 && entry.SetFields == nil {
+		entry.SetFields = make(map[string]bool)
+	}
 	for _, match := range matches {
 		key := match[1]
 		value := match[2]
+		entry.SetFields[key] = true
 
 		switch key {
 		case "charge":
@@ -133,26 +345,87 @@ func parseKeyValuePairsV2(entry *BatteryHistoryV2Entry, line string) {
 			entry.DataConn = value
 		case "phone_signal_strength":
 			entry.PhoneSignalStrength = value
+		case "phone_state":
+			entry.PhoneState = value
 		case "wifi_signal_strength":
-			if v, err := strconv.ParseInt(value, 10, 32); err == nil {
+			// The bucket may be followed by the RSSI, e.g. "4(-55dBm)".
+			m := wifiSignalPattern.FindStringSubmatch(value)
+			if m == nil {
+				break
+			}
+			if v, err := strconv.ParseInt(m[1], 10, 32); err == nil {
 				entry.WiFiSignalStrength = int32(v)
 			}
+			if m[2] != "" {
+				if v, err := strconv.ParseInt(m[2], 10, 32); err == nil {
+					entry.WiFiRSSI = int32(v)
+				}
+			}
 		case "wifi_suppl":
 			entry.WiFiSupplicantState = value
 		case "device_idle":
 			entry.DeviceIdleMode = value
+		case "nr_state":
+			entry.NRState = value
+		case "current_now":
+			if v, err := strconv.ParseInt(value, 10, 32); err == nil {
+				entry.CurrentNowMicroA = int32(v)
+			}
+		case "wifi_rssi":
+			if v, err := strconv.ParseInt(strings.TrimSuffix(value, "dBm"), 10, 32); err == nil {
+				entry.WiFiRSSI = int32(v)
+			}
+		case "cycle_count":
+			if v, err := strconv.ParseInt(value, 10, 32); err == nil {
+				entry.CycleCount = int32(v)
+			}
+		case "charge_time_remaining":
+			if v, err := strconv.ParseInt(value, 10, 32); err == nil {
+				entry.ChargeTimeRemainingSec = int32(v)
+			}
+		case "gps_signal_quality":
+			entry.GPSSignalQuality = value
+		case "power_save":
+			entry.BatterySaver = value == "on"
+		case "audio_output":
+			entry.AudioOutputRoute = value
+		case "battery_mfg_date":
+			entry.BatteryMfgDate = value
+		case "battery_serial":
+			entry.BatterySerial = value
+		case "charge_full":
+			if v, err := strconv.ParseInt(value, 10, 64); err == nil {
+				entry.ChargeFull = v
+			}
+		case "location_provider":
+			entry.LocationProvider = value
+		case "ble_scan_mode":
+			entry.BLEScanMode = value
 		case "modemRailChargemAh", "wifiRailChargemAh":
 			if v, err := strconv.ParseInt(value, 10, 64); err == nil {
 				entry.RailCharges[key] = v
 			}
+		case "wake_lock", "wake_reason", "video", "top", "fg":
+			// Parsed by parseWakeLocksV2, parseWakeReasonsV2, parseVideoV2 and parseTopAppV2.
+		default:
+			if entry.UnknownKeys == nil {
+				entry.UnknownKeys = make(map[string]string)
+			}
+			entry.UnknownKeys[key] = value
+			unknownKeyHandlerMu.RLock()
+			h := unknownKeyHandler
+			unknownKeyHandlerMu.RUnlock()
+			if h != nil {
+				h(key, value)
+			}
 		}
 	}
 }
 
 // parseStateTransitionsV2 extracts state transitions (+state or -state)
 func parseStateTransitionsV2(entry *BatteryHistoryV2Entry, line string) {
-	matches := stateTransitionPattern.FindAllStringSubmatch(line, -1)
-	for _, match := range matches {
+	for _, loc := range stateTransitionPattern.FindAllStringSubmatchIndex(line, -1) {
+		match := []string{line[loc[0]:loc[1]], line[loc[2]:loc[3]], line[loc[4]:loc[5]]}
 		transition := match[1] // +/- sign
 		state := match[2]      // state name
 
@@ -160,8 +433,10 @@ func parseStateTransitionsV2(entry *BatteryHistoryV2Entry, line string) {
 		if !strings.Contains(match[0], "=") {
 			isActive := transition == "+"
 			// Filter out partial matches that are part of larger tokens
-			// by checking if they're bounded by whitespace or special chars
-			idx := strings.Index(line, match[0])
+			// by checking if they're bounded by whitespace or special chars.
+			// The match's own position is used, since the same text may appear earlier as
+			// part of a longer token, e.g. +wifi in "+wifi_ap +wifi".
+			idx := loc[0]
 			if idx >= 0 {
 				// Check previous character if not at the start
 				prevOk := true
@@ -179,12 +454,70 @@ func parseStateTransitionsV2(entry *BatteryHistoryV2Entry, line string) {
 
 				if prevOk && nextOk {
 					entry.States[state] = isActive
+					setTypedStateV2(entry, state, isActive)
 				}
 			}
 		}
 	}
 }
 
+// locationProviderStates maps the states logged while a location provider is active to the provider.
+var locationProviderStates = map[string]string{
+	"sensor_gps":       "gps",
+	"fused_location":   "fused",
+	"network_location": "network",
+}
+
+// setTypedStateV2 sets the typed entry field corresponding to the given state, if there is one.
+func setTypedStateV2(entry *BatteryHistoryV2Entry, state string, active bool) {
+	switch state {
+	case "screen_doze":
+		entry.ScreenDoze = active
+	case "camera":
+		entry.CameraOn = active
+	case "flashlight":
+		entry.FlashlightOn = active
+	case "vibration":
+		entry.VibrationActive = active
+	case "ethernet":
+		entry.EthernetOn = active
+	case "battery_saver":
+		entry.BatterySaver = active
+	case "phone_in_call":
+		entry.InCall = active
+	case "step_detector":
+		entry.StepDetectorActive = active
+	case "usb_data":
+		entry.UsbDataActive = active
+	case "nfc":
+		entry.NFCOn = active
+	case "wifi_multicast":
+		entry.WiFiMulticast = active
+	case "bluetooth":
+		entry.BluetoothOn = active
+	case "ble_scan":
+		entry.BLEScanning = active
+	case "airplane_mode":
+		entry.AirplaneMode = active
+	case "ble_advertise":
+		entry.BLEAdvertising = active
+	case "wifi_ap":
+		entry.WiFiHotspot = active
+	case "sensor_gps", "fused_location", "network_location":
+		if active {
+			entry.LocationProvider = locationProviderStates[state]
+		}
+	default:
+		if m := cpuCoreRunningPattern.FindStringSubmatch(state); m != nil {
+			core, _ := strconv.Atoi(m[1])
+			if entry.CPUCoreRunning == nil {
+				entry.CPUCoreRunning = make(map[int]bool)
+			}
+			entry.CPUCoreRunning[core] = active
+		}
+	}
+}
+
 // parseWakeReasonsV2 extracts wake reasons from the history line
 func parseWakeReasonsV2(entry *BatteryHistoryV2Entry, line string) {
 	matches := wakeReasonPattern.FindAllStringSubmatch(line, -1)
@@ -194,8 +527,144 @@ func parseWakeReasonsV2(entry *BatteryHistoryV2Entry, line string) {
 	}
 }
 
-// ConvertToCSVEntry converts a V2 history entry to CSV format for backward compatibility
+// IsSet returns whether the given key was present on the history line, which allows
+// distinguishing a zero value from an absent one. e.g. IsSet("volt")
+func (entry *BatteryHistoryV2Entry) IsSet(field string) bool {
+	return entry.SetFields[field]
+}
+
+// EqualOption modifies how entries are compared by Equal.
+type EqualOption int
+
+const (
+	// IgnoreTimestamp makes Equal ignore the Timestamp and TimestampMs of the entries, so entries
+	// parsed from lines that only differ in time are equal.
+	IgnoreTimestamp EqualOption = iota
+)
+
+// Equal returns whether the entry and other have the same value for every field, including the
+// contents of the maps and slices. Nil and empty maps or slices are considered equal.
+func (entry *BatteryHistoryV2Entry) Equal(other *BatteryHistoryV2Entry, opts ...EqualOption) bool {
+	if entry == nil || other == nil {
+		return entry == other
+	}
+	a, b := *entry, *other
+	ignoreTimestamp := false
+	for _, o := range opts {
+		ignoreTimestamp = ignoreTimestamp || o == IgnoreTimestamp
+	}
+	// time.Time values can represent the same instant differently, so they're compared with Equal.
+	if !ignoreTimestamp && (a.TimestampMs != b.TimestampMs || !a.Timestamp.Equal(b.Timestamp)) {
+		return false
+	}
+	a.Timestamp, a.TimestampMs = time.Time{}, 0
+	b.Timestamp, b.TimestampMs = time.Time{}, 0
+	for _, v := range []reflect.Value{reflect.ValueOf(&a).Elem(), reflect.ValueOf(&b).Elem()} {
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Field(i)
+			if (f.Kind() == reflect.Map || f.Kind() == reflect.Slice) && f.Len() == 0 {
+				f.Set(reflect.Zero(f.Type()))
+			}
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// TemperatureCelsius returns the battery temperature in degrees Celsius.
+func (entry *BatteryHistoryV2Entry) TemperatureCelsius() float64 {
+	return float64(entry.Temperature) / 10
+}
+
+// TemperatureFahrenheit returns the battery temperature in degrees Fahrenheit.
+func (entry *BatteryHistoryV2Entry) TemperatureFahrenheit() float64 {
+	return entry.TemperatureCelsius()*9/5 + 32
+}
+
+// CurrentNowMilliA returns the instantaneous battery current in mA.
+// Negative values mean the battery is discharging.
+func (entry *BatteryHistoryV2Entry) CurrentNowMilliA() float64 {
+	return float64(entry.CurrentNowMicroA) / 1000
+}
+
+// parseTopAppV2 extracts the apps that moved to or left the top of the screen or the foreground
+// from the history line.
+func parseTopAppV2(entry *BatteryHistoryV2Entry, line string) {
+	for _, m := range topAppPattern.FindAllStringSubmatch(line, -1) {
+		switch {
+		case m[2] == "top" && m[1] == "+":
+			entry.TopApp = m[3]
+		case m[2] == "top":
+			entry.TopAppLeft = m[3]
+		case m[1] == "+":
+			entry.ForegroundStarted = append(entry.ForegroundStarted, m[3])
+		default:
+			entry.ForegroundStopped = append(entry.ForegroundStopped, m[3])
+		}
+	}
+}
+
+// parseVideoV2 extracts video transitions from the history line, along with the decoder and
+// resolution qualifiers some devices log, e.g. +video=hw,1080p.
+func parseVideoV2(entry *BatteryHistoryV2Entry, line string) {
+	for _, m := range videoPattern.FindAllStringSubmatch(line, -1) {
+		active := m[1] == "+"
+		entry.States["video"] = active
+		entry.VideoOn = active
+		if m[2] == "" {
+			continue
+		}
+		for _, q := range strings.Split(m[2], ",") {
+			switch q {
+			case "":
+			case "hw", "sw":
+				entry.VideoDecoder = q
+			default:
+				entry.VideoResolution = q
+			}
+		}
+	}
+}
+
+// parseWakeLocksV2 extracts wake lock acquire and release transitions from the history line
+func parseWakeLocksV2(entry *BatteryHistoryV2Entry, line string) {
+	for _, m := range wakeLockPattern.FindAllStringSubmatchIndex(line, -1) {
+		// Only match whole tokens, e.g. not +wake_lock_in.
+		if (m[0] > 0 && line[m[0]-1] != ' ') || (m[1] < len(line) && line[m[1]] != ' ') {
+			continue
+		}
+		t := WakeLockTransition{Active: line[m[2]:m[3]] == "+"}
+		if m[4] >= 0 {
+			t.UID = line[m[4]:m[5]]
+			t.Tag = line[m[6]:m[7]]
+		}
+		entry.WakeLocks = append(entry.WakeLocks, t)
+	}
+}
+
+// TemperatureUnit specifies how temperatures are rendered in the CSV output.
+type TemperatureUnit int
+
+const (
+	// DeciCelsius renders temperatures as reported in the history, in tenths of a degree Celsius, e.g. "temp=254".
+	DeciCelsius TemperatureUnit = iota
+	// Fahrenheit renders temperatures in degrees Fahrenheit to one decimal place, e.g. "temp=77.7F".
+	Fahrenheit
+)
+
+// CSVEntryOptions modifies how history entries are converted to CSV entries.
+type CSVEntryOptions struct {
+	TemperatureUnit TemperatureUnit
+}
+
+// ConvertToCSVEntry converts a V2 history entry to CSV format for backward compatibility.
+// The entry describes a single point in time, so End is the same as Start.
+// Start and End are in ms, and are written in ms unless a csv.TimestampFormat is given to the writer.
 func (entry *BatteryHistoryV2Entry) ConvertToCSVEntry() csv.Entry {
+	return entry.ConvertToCSVEntryWithOptions(CSVEntryOptions{})
+}
+
+// ConvertToCSVEntryWithOptions is the same as ConvertToCSVEntry, but uses the given options.
+func (entry *BatteryHistoryV2Entry) ConvertToCSVEntryWithOptions(opts CSVEntryOptions) csv.Entry {
 	// Build value string from important fields
 	values := []string{}
 	if entry.Status != "" {
@@ -208,30 +677,70 @@ func (entry *BatteryHistoryV2Entry) ConvertToCSVEntry() csv.Entry {
 		values = append(values, fmt.Sprintf("volt=%d", entry.Voltage))
 	}
 	if entry.Temperature > 0 {
-		values = append(values, fmt.Sprintf("temp=%d", entry.Temperature))
+		switch opts.TemperatureUnit {
+		case Fahrenheit:
+			values = append(values, fmt.Sprintf("temp=%.1fF", entry.TemperatureFahrenheit()))
+		default:
+			values = append(values, fmt.Sprintf("temp=%d", entry.Temperature))
+		}
 	}
 
 	return csv.Entry{
 		Desc:       "Battery state change",
 		Type:       "Battery State",
 		Start:      entry.TimestampMs,
+		End:        entry.TimestampMs,
 		Value:      strings.Join(values, ","),
 		Identifier: "system",
 	}
 }
 
-// DetectHistoryFormatVersion detects whether the battery history is Format 1 or Format 2
+// History formats returned by DetectHistoryFormatVersion.
+const (
+	// HistoryFormatClassic is the numeric history format, e.g. "9,h,0,Bl=52".
+	HistoryFormatClassic = 1
+	// HistoryFormatV2 is the readable Format 2 history, e.g. "01-11 12:11:14.405 075 c4002820 +running".
+	HistoryFormatV2 = 2
+	// HistoryFormatCheckin is the aggregated stats output of "dumpsys batterystats --checkin",
+	// e.g. "9,0,i,vers,36,214,NRD90M,NRD90M". It is parsed by ParseCheckinFormat.
+	HistoryFormatCheckin = 3
+)
+
+// checkinStatsLinePattern matches an aggregated stats line of the checkin format: the checkin version,
+// UID, aggregation type ("i" for info, "l" for last, "u" for unplugged, "c" for current) and section.
+// Example: 9,0,i,vers,36,214,NRD90M,NRD90M or 9,10012,l,wl,*alarm*,0,f,0
+var checkinStatsLinePattern = regexp.MustCompile(`^\d+,\d+,[ilcu],\w+,`)
+
+// DetectHistoryFormatVersion detects whether the battery history is Format 1, Format 2 or the checkin
+// format. Format 1 and Format 2 are detected by the first history line that matches one of them.
+// Checkin dumps include "9,h," history lines after their aggregated stats lines, so they are only
+// detected as the checkin format if they contain no history lines.
 func DetectHistoryFormatVersion(historyText string) int {
 	// Format 2 uses readable format with timestamps like "01-11 12:11:14.405"
 	// Format 1 uses numeric format "9,h,0,Bl=..."
 	lines := strings.Split(historyText, "\n")
+	checkin := false
 	for _, line := range lines {
 		if strings.HasPrefix(strings.TrimSpace(line), "9,h,") {
-			return 1 // Classic format
+			return HistoryFormatClassic
 		}
 		if historyLinePatternV2.MatchString(line) {
-			return 2 // Modern format
+			return HistoryFormatV2
+		}
+		if checkinStatsLinePattern.MatchString(strings.TrimSpace(line)) {
+			checkin = true
 		}
 	}
-	return 1 // Default to format 1
+	if checkin {
+		return HistoryFormatCheckin
+	}
+	return HistoryFormatClassic // Default to format 1
+}
+
+// ParseCheckinFormat is the entry point for parsing history detected as HistoryFormatCheckin.
+// The aggregated stats don't contain a timeline of history entries, so parsing them isn't
+// supported yet and an error is always returned. Use checkinparse.ParseBatteryStats to read
+// the aggregated stats themselves.
+func ParseCheckinFormat(text string) ([]*BatteryHistoryV2Entry, []error) {
+	return nil, []error{errors.New("parsing the checkin format is not supported")}
 }
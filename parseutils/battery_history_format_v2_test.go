@@ -15,6 +15,8 @@
 package parseutils
 
 import (
+	"math"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -79,6 +81,286 @@ func TestParseHistoryV2Line(t *testing.T) {
 				return e.DeviceIdleMode == "full" && e.WiFiSupplicantState == "completed"
 			},
 		},
+		{
+			name:    "5G NR connection substate",
+			line:    `01-11 12:11:14.405 075 c4002820 data_conn=nr nr_state=connected`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.DataConn == "nr" && e.NRState == "connected"
+			},
+		},
+		{
+			name:    "Discharging current",
+			line:    `01-11 12:11:14.405 075 c4002820 status=discharging current_now=-250000`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.CurrentNowMicroA == -250000 && e.CurrentNowMilliA() == -250 && len(e.States) == 0
+			},
+		},
+		{
+			name:    "WiFi signal strength with RSSI",
+			line:    `01-11 12:11:14.405 075 c4002820 +wifi wifi_signal_strength=4(-55dBm)`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.WiFiSignalStrength == 4 && e.WiFiRSSI == -55
+			},
+		},
+		{
+			name:    "WiFi RSSI token",
+			line:    `01-11 12:11:14.405 075 c4002820 wifi_signal_strength=3 wifi_rssi=-67dBm`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.WiFiSignalStrength == 3 && e.WiFiRSSI == -67
+			},
+		},
+		{
+			name:    "Screen doze (always-on display)",
+			line:    `01-11 12:11:14.405 075 c4002820 -screen +screen_doze`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.ScreenDoze && !e.States["screen"]
+			},
+		},
+		{
+			name:    "Battery cycle count",
+			line:    `01-11 12:11:14.405 075 c4002820 status=discharging cycle_count=412`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.CycleCount == 412
+			},
+		},
+		{
+			name:    "Flashlight on without camera",
+			line:    `01-11 12:11:14.405 075 c4002820 +flashlight -camera`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.FlashlightOn && !e.CameraOn && e.States["camera"] == false
+			},
+		},
+		{
+			name:    "Wake lock acquire and release",
+			line:    `01-11 12:11:14.405 075 c4002820 +wake_lock=1000:"*alarm*:TIME_TICK" -wake_lock=u0a231:"*alarm*" +running`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return len(e.WakeLocks) == 2 && e.WakeLocks[0] == WakeLockTransition{WakeLock{"1000", "*alarm*:TIME_TICK"}, true} && e.WakeLocks[1] == WakeLockTransition{WakeLock{"u0a231", "*alarm*"}, false}
+			},
+		},
+		{
+			name:    "Release of all wake locks",
+			line:    `01-11 12:11:14.405 075 c4002820 -wake_lock -running`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return len(e.WakeLocks) == 1 && !e.WakeLocks[0].Active && e.WakeLocks[0].UID == ""
+			},
+		},
+		{
+			name:    "Charge time remaining",
+			line:    `01-11 12:11:14.405 074 c4002820 status=charging charge_time_remaining=3600`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.ChargeTimeRemainingSec == 3600 && e.Status == "charging"
+			},
+		},
+		{
+			name:    "Vibration state",
+			line:    `01-11 12:11:14.405 075 c4002820 +vibration`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.VibrationActive && e.States["vibration"]
+			},
+		},
+		{
+			name:    "GPS signal quality",
+			line:    `01-11 12:11:14.405 075 c4002820 +gps gps_signal_quality=poor`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.GPSSignalQuality == "poor" && e.States["gps"] && len(e.UnknownKeys) == 0
+			},
+		},
+		{
+			name:    "Ethernet state",
+			line:    `01-11 12:11:14.405 075 c4002820 +ethernet`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.EthernetOn
+			},
+		},
+		{
+			name:    "Per-core CPU running",
+			line:    `01-11 12:11:14.405 075 c4002820 +cpu0_running +cpu3_running -cpu1_running`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return reflect.DeepEqual(e.CPUCoreRunning, map[int]bool{0: true, 1: false, 3: true})
+			},
+		},
+		{
+			name:    "Battery saver state",
+			line:    `01-11 12:11:14.405 075 c4002820 +battery_saver`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.BatterySaver
+			},
+		},
+		{
+			name:    "Battery saver key",
+			line:    `01-11 12:11:14.405 075 c4002820 power_save=on`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.BatterySaver && len(e.UnknownKeys) == 0
+			},
+		},
+		{
+			name:    "Phone call state",
+			line:    `01-11 12:11:14.405 075 c4002820 +phone_in_call`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.InCall
+			},
+		},
+		{
+			name:    "Step detector state",
+			line:    `01-11 12:11:14.405 075 c4002820 +step_detector`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.StepDetectorActive
+			},
+		},
+		{
+			name:    "Audio output route",
+			line:    `01-11 12:11:14.405 075 c4002820 +audio_output=bt`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.AudioOutputRoute == "bt" && len(e.UnknownKeys) == 0
+			},
+		},
+		{
+			name:    "Battery manufacture date",
+			line:    `01-11 12:11:14.405 075 c4002820 battery_mfg_date=2023-04-12`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.BatteryMfgDate == "2023-04-12" && len(e.UnknownKeys) == 0
+			},
+		},
+		{
+			name:    "Battery serial",
+			line:    `01-11 12:11:14.405 075 c4002820 battery_serial=BT1234567`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.BatterySerial == "BT1234567"
+			},
+		},
+		{
+			name:    "USB data state",
+			line:    `01-11 12:11:14.405 075 c4002820 +usb_data plug=usb`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.UsbDataActive && e.PlugType == "usb"
+			},
+		},
+		{
+			name:    "NFC state",
+			line:    `01-11 12:11:14.405 075 c4002820 +nfc`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.NFCOn
+			},
+		},
+		{
+			name:    "WiFi multicast state",
+			line:    `01-11 12:11:14.405 075 c4002820 +wifi_multicast`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.WiFiMulticast
+			},
+		},
+		{
+			name:    "Classic Bluetooth without BLE scan",
+			line:    `01-11 12:11:14.405 075 c4002820 +bluetooth`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.BluetoothOn && !e.BLEScanning
+			},
+		},
+		{
+			name:    "BLE scan without classic Bluetooth",
+			line:    `01-11 12:11:14.405 075 c4002820 +ble_scan`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.BLEScanning && !e.BluetoothOn
+			},
+		},
+		{
+			name:    "Airplane mode state",
+			line:    `01-11 12:11:14.405 075 c4002820 +airplane_mode data_conn=none`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.AirplaneMode && e.DataConn == "none"
+			},
+		},
+		{
+			name:    "BLE advertising state",
+			line:    `01-11 12:11:14.405 075 c4002820 +ble_advertise`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.BLEAdvertising && !e.BLEScanning
+			},
+		},
+		{
+			name:    "Video with decoder and resolution",
+			line:    `01-11 12:11:14.405 075 c4002820 +video=hw,1080p +running`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.VideoOn && e.VideoDecoder == "hw" && e.VideoResolution == "1080p" && e.States["video"] && e.States["running"] && len(e.UnknownKeys) == 0
+			},
+		},
+		{
+			name:    "Fused location provider",
+			line:    `01-11 12:11:14.405 075 c4002820 +fused_location +gps`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.LocationProvider == "fused" && e.States["fused_location"] && e.States["gps"]
+			},
+		},
+		{
+			name:    "Location provider key",
+			line:    `01-11 12:11:14.405 075 c4002820 location_provider=network`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.LocationProvider == "network" && len(e.UnknownKeys) == 0
+			},
+		},
+		{
+			name:    "Low latency BLE scan mode",
+			line:    `01-11 12:11:14.405 075 c4002820 +ble_scan ble_scan_mode=low_latency`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.BLEScanning && e.BLEScanMode == "low_latency" && len(e.UnknownKeys) == 0
+			},
+		},
+		{
+			name:    "Foreground app change",
+			line:    `01-11 12:11:14.405 075 c4002820 -top=u0a50:"com.android.launcher" +top=u0a123:"com.android.camera" +running`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.TopApp == "com.android.camera" && e.TopAppLeft == "com.android.launcher" && e.States["running"] && len(e.UnknownKeys) == 0
+			},
+		},
+		{
+			name:    "WiFi hotspot",
+			line:    `01-11 12:11:14.405 075 c4002820 +wifi_ap +wifi`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return e.WiFiHotspot && e.States["wifi"]
+			},
+		},
+		{
+			name:    "Foreground process transitions",
+			line:    `01-11 12:11:14.405 075 c4002820 +fg=u0a99:"com.example.tracker" -fg=u0a50:"com.example.music"`,
+			wantErr: false,
+			checks: func(e *BatteryHistoryV2Entry) bool {
+				return len(e.ForegroundStarted) == 1 && e.ForegroundStarted[0] == "com.example.tracker" && len(e.ForegroundStopped) == 1 && e.ForegroundStopped[0] == "com.example.music" && len(e.UnknownKeys) == 0
+			},
+		},
 		{
 			name:    "Invalid format should error",
 			line:    `invalid line format`,
@@ -136,6 +418,21 @@ func TestDetectHistoryFormatVersion(t *testing.T) {
 			history: "",
 			want:    1,
 		},
+		{
+			name: "Checkin format",
+			history: `9,0,i,vers,36,214,NRD90M,NRD90M
+9,0,i,uid,10012,com.google.android.gms
+9,0,l,bt,0,8804,8804,8804,8804,1484346554437,8745,8745,0,100,100,0,0,0`,
+			want: HistoryFormatCheckin,
+		},
+		{
+			name: "Checkin dump with history lines",
+			history: `9,0,i,vers,36,214,NRD90M,NRD90M
+9,0,i,uid,10012,com.google.android.gms
+9,h,0:RESET:TIME:1484346554437
+9,h,0,Bl=100,Bs=d,Bh=g,Bp=n,Bt=250,Bv=4297,+r,+s,+Wr`,
+			want: HistoryFormatClassic,
+		},
 		{
 			name: "Mixed formats (should detect Format 1 line first)",
 			history: `9,h,0,Bl=52
@@ -154,6 +451,14 @@ func TestDetectHistoryFormatVersion(t *testing.T) {
 	}
 }
 
+// TestParseCheckinFormat tests that the checkin format is reported as unsupported.
+func TestParseCheckinFormat(t *testing.T) {
+	entries, errs := ParseCheckinFormat("9,0,i,vers,36,214,NRD90M,NRD90M")
+	if len(entries) != 0 || len(errs) != 1 {
+		t.Errorf("ParseCheckinFormat() = %v, %v, want no entries and an unsupported error", entries, errs)
+	}
+}
+
 // TestParseStateTransitionsV2 tests extraction of +state and -state transitions
 func TestParseStateTransitionsV2(t *testing.T) {
 	tests := []struct {
@@ -329,6 +634,25 @@ func TestConvertToCSVEntry(t *testing.T) {
 	if !strings.Contains(csvEntry.Value, "volt=4170") {
 		t.Errorf("ConvertToCSVEntry() Value missing voltage: %s", csvEntry.Value)
 	}
+
+	if csvEntry.End != csvEntry.Start {
+		t.Errorf("ConvertToCSVEntry() End = %d, want the same as Start %d", csvEntry.End, csvEntry.Start)
+	}
+}
+
+// TestConvertToCSVEntryFahrenheit tests that temperatures can be rendered in Fahrenheit.
+func TestConvertToCSVEntryFahrenheit(t *testing.T) {
+	entry := &BatteryHistoryV2Entry{Status: "discharging", Temperature: 254}
+
+	if got := entry.TemperatureFahrenheit(); math.Abs(got-77.72) > 1e-9 {
+		t.Errorf("TemperatureFahrenheit() = %v, want 77.72", got)
+	}
+	if got := entry.ConvertToCSVEntryWithOptions(CSVEntryOptions{TemperatureUnit: Fahrenheit}).Value; !strings.Contains(got, "temp=77.7F") {
+		t.Errorf("ConvertToCSVEntryWithOptions(Fahrenheit) Value = %q, want temp=77.7F", got)
+	}
+	if got := entry.ConvertToCSVEntry().Value; !strings.Contains(got, "temp=254") {
+		t.Errorf("ConvertToCSVEntry() Value = %q, want temp=254", got)
+	}
 }
 
 // TestModernBugreportIntegration tests with actual modern bugreport format samples
@@ -379,3 +703,178 @@ func TestModernBugreportIntegration(t *testing.T) {
 		t.Error("Expected state transitions to be parsed")
 	}
 }
+
+// TestIsSet tests that only keys present on the history line are reported as set.
+func TestIsSet(t *testing.T) {
+	e, err := ParseHistoryV2Line(`01-11 12:11:14.405 075 c4002820 status=discharging temp=0`)
+	if err != nil {
+		t.Fatalf("ParseHistoryV2Line() error = %v", err)
+	}
+	if e.IsSet("volt") {
+		t.Error(`IsSet("volt") = true, want false when volt is not on the line`)
+	}
+	if !e.IsSet("temp") {
+		t.Error(`IsSet("temp") = false, want true for a zero temp on the line`)
+	}
+	if !e.IsSet("status") {
+		t.Error(`IsSet("status") = false, want true`)
+	}
+}
+
+// TestEqual tests the comparison of entries, including their maps and slices.
+func TestEqual(t *testing.T) {
+	parse := func(line string) *BatteryHistoryV2Entry {
+		e, err := ParseHistoryV2Line(line)
+		if err != nil {
+			t.Fatalf("ParseHistoryV2Line(%q) error = %v", line, err)
+		}
+		return e
+	}
+	line := `01-11 12:00:00.000 075 c4002820 status=discharging volt=4100 +running +wake_lock=1000:"*alarm*" wake_reason=0:"100 rtc_alarm"`
+	a, b := parse(line), parse(line)
+	if !a.Equal(b) {
+		t.Errorf("Equal() = false for entries parsed from the same line, want true:\n%+v\n%+v", a, b)
+	}
+
+	later := parse(`01-11 12:00:05.000 075 c4002820 status=discharging volt=4100 +running +wake_lock=1000:"*alarm*" wake_reason=0:"100 rtc_alarm"`)
+	if a.Equal(later) {
+		t.Error("Equal() = true for entries with different timestamps, want false")
+	}
+	if !a.Equal(later, IgnoreTimestamp) {
+		t.Error("Equal(IgnoreTimestamp) = false for entries that only differ in time, want true")
+	}
+
+	b.States["gps"] = true
+	if a.Equal(b) {
+		t.Error("Equal() = true for entries with different states, want false")
+	}
+
+	empty := parse(`01-11 12:00:00.000 075 c4002820 volt=4100`)
+	emptyMaps := *empty
+	emptyMaps.UnknownKeys = map[string]string{}
+	emptyMaps.WakeLocks = []WakeLockTransition{}
+	if !empty.Equal(&emptyMaps) {
+		t.Error("Equal() = false for nil and empty maps, want true")
+	}
+	if empty.Equal(nil) {
+		t.Error("Equal(nil) = true, want false")
+	}
+}
+
+// TestParseHistoryV2LineWithContext tests that platform specific states are only recognized on their platform.
+func TestParseHistoryV2LineWithContext(t *testing.T) {
+	line := `01-11 12:00:00.000 075 c4002820 +body_sensor +hdmi`
+	tests := []struct {
+		desc     string
+		platform Platform
+		want     map[string]bool
+	}{
+		{
+			desc:     "Wear",
+			platform: PlatformWear,
+			want:     map[string]bool{"body_sensor": true},
+		},
+		{
+			desc:     "TV",
+			platform: PlatformTV,
+			want:     map[string]bool{"hdmi": true},
+		},
+		{
+			desc:     "Phone",
+			platform: PlatformPhone,
+			want:     map[string]bool{},
+		},
+	}
+	for _, test := range tests {
+		e, err := ParseHistoryV2LineWithContext(ParseContext{Platform: test.platform}, line)
+		if err != nil {
+			t.Fatalf("%v: ParseHistoryV2LineWithContext(%q) unexpected error: %v", test.desc, line, err)
+		}
+		if !reflect.DeepEqual(e.PlatformStates, test.want) {
+			t.Errorf("%v: ParseHistoryV2LineWithContext(%q).PlatformStates = %v, want %v", test.desc, line, e.PlatformStates, test.want)
+		}
+		if !e.States["body_sensor"] || !e.States["hdmi"] {
+			t.Errorf("%v: ParseHistoryV2LineWithContext(%q).States = %v, want both states in the generic map", test.desc, line, e.States)
+		}
+	}
+}
+
+// TestSetUnknownKeyHandler tests that the handler is called for unrecognized keys only.
+func TestSetUnknownKeyHandler(t *testing.T) {
+	got := make(map[string]string)
+	SetUnknownKeyHandler(func(key, value string) {
+		got[key] = value
+	})
+	defer SetUnknownKeyHandler(nil)
+
+	line := `01-11 12:11:14.405 075 c4002820 status=discharging new_metric=42 +wake_lock=u0a55:"sync" wake_reason=0:"rtc_alarm"`
+	e, err := ParseHistoryV2Line(line)
+	if err != nil {
+		t.Fatalf("ParseHistoryV2Line(%q) unexpected error: %v", line, err)
+	}
+	want := map[string]string{"new_metric": "42"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseHistoryV2Line(%q) called the unknown key handler with %v, want %v", line, got, want)
+	}
+	if !reflect.DeepEqual(e.UnknownKeys, want) {
+		t.Errorf("ParseHistoryV2Line(%q).UnknownKeys = %v, want %v", line, e.UnknownKeys, want)
+	}
+}
+
+// TestWakeLockTransitionApply tests that transitions update the held wake locks and return the released ones.
+func TestWakeLockTransitionApply(t *testing.T) {
+	sync := WakeLock{UID: "u0a55", Tag: "sync"}
+	alarm := WakeLock{UID: "1000", Tag: "*alarm*"}
+	tests := []struct {
+		desc         string
+		held         map[WakeLock]int64
+		t            WakeLockTransition
+		wantHeld     map[WakeLock]int64
+		wantReleased map[WakeLock]int64
+	}{
+		{
+			desc:         "Acquire",
+			held:         map[WakeLock]int64{},
+			t:            WakeLockTransition{WakeLock: sync, Active: true},
+			wantHeld:     map[WakeLock]int64{sync: 2000},
+			wantReleased: map[WakeLock]int64{},
+		},
+		{
+			desc:         "Acquire while held keeps the acquisition time",
+			held:         map[WakeLock]int64{sync: 1000},
+			t:            WakeLockTransition{WakeLock: sync, Active: true},
+			wantHeld:     map[WakeLock]int64{sync: 1000},
+			wantReleased: map[WakeLock]int64{},
+		},
+		{
+			desc:         "Release",
+			held:         map[WakeLock]int64{sync: 1000, alarm: 1500},
+			t:            WakeLockTransition{WakeLock: sync},
+			wantHeld:     map[WakeLock]int64{alarm: 1500},
+			wantReleased: map[WakeLock]int64{sync: 1000},
+		},
+		{
+			desc:         "Release not held",
+			held:         map[WakeLock]int64{alarm: 1500},
+			t:            WakeLockTransition{WakeLock: sync},
+			wantHeld:     map[WakeLock]int64{alarm: 1500},
+			wantReleased: map[WakeLock]int64{},
+		},
+		{
+			desc:         "Release all",
+			held:         map[WakeLock]int64{sync: 1000, alarm: 1500},
+			t:            WakeLockTransition{},
+			wantHeld:     map[WakeLock]int64{},
+			wantReleased: map[WakeLock]int64{sync: 1000, alarm: 1500},
+		},
+	}
+	for _, test := range tests {
+		released := test.t.Apply(test.held, 2000)
+		if !reflect.DeepEqual(test.held, test.wantHeld) {
+			t.Errorf("%v: Apply() held = %v, want %v", test.desc, test.held, test.wantHeld)
+		}
+		if !reflect.DeepEqual(released, test.wantReleased) {
+			t.Errorf("%v: Apply() = %v, want %v", test.desc, released, test.wantReleased)
+		}
+	}
+}
@@ -0,0 +1,815 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parseutils
+
+// battery_history_v2_analysis.go contains helpers that analyze parsed Format 2 history entries.
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/battery-historian/csv"
+)
+
+// attributedStates are the high power states that are attributed to the wake locks held while they are active.
+var attributedStates = []string{"sensor", "gps", "mobile_radio"}
+
+// StateAttribution associates the high power states active at a history entry with the wake locks held at the time.
+type StateAttribution struct {
+	TimestampMs int64
+	// WakeLocks maps each active attributed state (e.g. "sensor") to the wake locks held at the time,
+	// sorted by owner UID and tag. The owner UID of each wake lock identifies the app responsible.
+	WakeLocks map[string][]WakeLock
+}
+
+// CorrelateStatesWithWakelocks returns, for each entry at which a sensor, GPS or mobile radio state is
+// active and at least one wake lock is held, the wake locks the active states are attributed to.
+// Since history lines only contain transitions, states and wake locks are carried forward from previous
+// entries. Entries are expected in timestamp order.
+func CorrelateStatesWithWakelocks(entries []*BatteryHistoryV2Entry) []StateAttribution {
+	var res []StateAttribution
+	active := make(map[string]bool)
+	held := make(map[WakeLock]int64)
+	for _, e := range entries {
+		for _, s := range attributedStates {
+			if on, ok := e.States[s]; ok {
+				active[s] = on
+			}
+		}
+		for _, t := range e.WakeLocks {
+			t.Apply(held, e.TimestampMs)
+		}
+		if len(held) == 0 {
+			continue
+		}
+		var wls []WakeLock
+		for wl := range held {
+			wls = append(wls, wl)
+		}
+		sort.Slice(wls, func(i, j int) bool {
+			if wls[i].UID != wls[j].UID {
+				return wls[i].UID < wls[j].UID
+			}
+			return wls[i].Tag < wls[j].Tag
+		})
+		a := StateAttribution{
+			TimestampMs: e.TimestampMs,
+			WakeLocks:   make(map[string][]WakeLock),
+		}
+		for _, s := range attributedStates {
+			if active[s] {
+				a.WakeLocks[s] = wls
+			}
+		}
+		if len(a.WakeLocks) > 0 {
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+// VoltageEnvelope returns the minimum and maximum voltage (in mV) reported by the entries with
+// timestamps (in ms) in the inclusive range [start, end]. Entries that don't report a voltage are
+// ignored. Both values are zero if no entry in the range reports a voltage.
+func VoltageEnvelope(entries []*BatteryHistoryV2Entry, start, end int64) (min, max int32) {
+	found := false
+	for _, e := range entries {
+		if e.TimestampMs < start || e.TimestampMs > end || !e.IsSet("volt") {
+			continue
+		}
+		if !found || e.Voltage < min {
+			min = e.Voltage
+		}
+		if !found || e.Voltage > max {
+			max = e.Voltage
+		}
+		found = true
+	}
+	return min, max
+}
+
+// SummarizeWakeReasons returns the number of times each wake reason was reported in the given entries.
+func SummarizeWakeReasons(entries []*BatteryHistoryV2Entry) map[string]int {
+	res := make(map[string]int)
+	for _, e := range entries {
+		for r := range e.WakeReasons {
+			res[r]++
+		}
+	}
+	return res
+}
+
+// GPSWakeupCounts returns the number of times each wake reason was reported while GPS was on,
+// including on the entry that turned it on, so the wakeups can be attributed to location. GPS state
+// is carried forward from previous entries, which are expected in timestamp order.
+func GPSWakeupCounts(entries []*BatteryHistoryV2Entry) map[string]int {
+	res := make(map[string]int)
+	gps := false
+	for _, e := range entries {
+		on, ok := e.States["gps"]
+		if ok && on {
+			gps = true
+		}
+		if gps {
+			for r := range e.WakeReasons {
+				res[r]++
+			}
+		}
+		if ok && !on {
+			gps = false
+		}
+	}
+	return res
+}
+
+// HistoryV2Summary holds the headline statistics for a span of Format 2 history.
+type HistoryV2Summary struct {
+	ScreenOnMs int64
+	RunningMs  int64
+	// Wakeups is the number of wake reasons reported.
+	Wakeups         int
+	MinBatteryLevel int32
+	MaxBatteryLevel int32
+	// EstimatedMAhConsumed is the sum of the drops in the reported battery charge.
+	// Increases while charging are not subtracted.
+	EstimatedMAhConsumed int64
+}
+
+// Summarize returns the headline statistics for the given entries, which are expected in timestamp order.
+func Summarize(entries []*BatteryHistoryV2Entry) HistoryV2Summary {
+	var s HistoryV2Summary
+	if len(entries) == 0 {
+		return s
+	}
+	for _, iv := range BuildHistoryV2Intervals(entries) {
+		switch iv.Metric {
+		case "Screen":
+			s.ScreenOnMs += iv.End - iv.Start
+		case csv.CPURunning:
+			s.RunningMs += iv.End - iv.Start
+		}
+	}
+	for _, n := range SummarizeWakeReasons(entries) {
+		s.Wakeups += n
+	}
+	s.MinBatteryLevel = entries[0].BatteryPercent
+	s.MaxBatteryLevel = entries[0].BatteryPercent
+	// The charge on history lines is reported in mAh.
+	lastCharge := int64(-1)
+	for _, e := range entries {
+		if e.BatteryPercent < s.MinBatteryLevel {
+			s.MinBatteryLevel = e.BatteryPercent
+		}
+		if e.BatteryPercent > s.MaxBatteryLevel {
+			s.MaxBatteryLevel = e.BatteryPercent
+		}
+		if !e.IsSet("charge") {
+			continue
+		}
+		if lastCharge >= 0 && e.ChargeMicroAh < lastCharge {
+			s.EstimatedMAhConsumed += lastCharge - e.ChargeMicroAh
+		}
+		lastCharge = e.ChargeMicroAh
+	}
+	return s
+}
+
+// DetectClockSkew returns the indices of the entries whose timestamp is earlier than that of the
+// previous entry, without the entry carrying a RESET:TIME or TIME marker to explain the change.
+// Such jumps usually mean the system time was adjusted, and would corrupt interval calculations.
+func DetectClockSkew(entries []*BatteryHistoryV2Entry) []int {
+	var res []int
+	for i := 1; i < len(entries); i++ {
+		if entries[i].TimestampMs < entries[i-1].TimestampMs && !entries[i].TimeChanged {
+			res = append(res, i)
+		}
+	}
+	return res
+}
+
+// HistoryV2Bucket holds the aggregated history for a fixed time window.
+type HistoryV2Bucket struct {
+	StartMs int64
+	EndMs   int64
+	// NumEntries is the number of entries in the window.
+	NumEntries int
+	// AvgVoltage and AvgTemperature are the averages of the readings in the window. If there were no
+	// readings in the window, they are the last readings before it.
+	AvgVoltage     float64
+	AvgTemperature float64
+	// States contains every state that was active at any point during the window, including
+	// states carried forward from before the window.
+	States map[string]bool
+}
+
+// Bucketize aggregates the given entries into consecutive windows of windowMs, starting at the
+// first entry's timestamp. Entries are expected in timestamp order. Windows without any entries
+// carry forward the last known readings and states.
+func Bucketize(entries []*BatteryHistoryV2Entry, windowMs int64) []HistoryV2Bucket {
+	if len(entries) == 0 || windowMs <= 0 {
+		return nil
+	}
+	start := entries[0].TimestampMs
+	end := entries[len(entries)-1].TimestampMs
+	active := make(map[string]bool)
+	var lastVolt, lastTemp float64
+	var res []HistoryV2Bucket
+	i := 0
+	for bStart := start; bStart <= end; bStart += windowMs {
+		b := HistoryV2Bucket{
+			StartMs: bStart,
+			EndMs:   bStart + windowMs,
+			States:  make(map[string]bool),
+		}
+		for s := range active {
+			b.States[s] = true
+		}
+		var voltSum, tempSum float64
+		var voltNum, tempNum int
+		for ; i < len(entries) && entries[i].TimestampMs < b.EndMs; i++ {
+			e := entries[i]
+			b.NumEntries++
+			if e.IsSet("volt") {
+				voltSum += float64(e.Voltage)
+				voltNum++
+			}
+			if e.IsSet("temp") {
+				tempSum += float64(e.Temperature)
+				tempNum++
+			}
+			for s, on := range e.States {
+				if on {
+					active[s] = true
+					b.States[s] = true
+				} else {
+					delete(active, s)
+				}
+			}
+		}
+		if voltNum > 0 {
+			lastVolt = voltSum / float64(voltNum)
+		}
+		if tempNum > 0 {
+			lastTemp = tempSum / float64(tempNum)
+		}
+		b.AvgVoltage = lastVolt
+		b.AvgTemperature = lastTemp
+		res = append(res, b)
+	}
+	return res
+}
+
+// ChargingSession is a period during which the device was charging.
+type ChargingSession struct {
+	StartMs    int64
+	EndMs      int64
+	StartLevel int32
+	EndLevel   int32
+	Duration   time.Duration
+}
+
+// BuildChargingSessions returns the charging sessions found in the given entries, which are expected
+// in timestamp order. A session starts when the status changes to charging, continues while the battery
+// is charging or full, and ends at the first entry with any other status. A session still in progress
+// ends at the last entry.
+func BuildChargingSessions(entries []*BatteryHistoryV2Entry) []ChargingSession {
+	var res []ChargingSession
+	var cur *ChargingSession
+	end := func(e *BatteryHistoryV2Entry) {
+		cur.EndMs = e.TimestampMs
+		cur.EndLevel = e.BatteryPercent
+		cur.Duration = time.Duration(cur.EndMs-cur.StartMs) * time.Millisecond
+		res = append(res, *cur)
+		cur = nil
+	}
+	for _, e := range entries {
+		if e.Status == "" {
+			continue
+		}
+		charging := e.Status == "charging" || e.Status == "full"
+		switch {
+		case cur == nil && e.Status == "charging":
+			cur = &ChargingSession{StartMs: e.TimestampMs, StartLevel: e.BatteryPercent}
+		case cur != nil && !charging:
+			end(e)
+		}
+	}
+	if cur != nil {
+		end(entries[len(entries)-1])
+	}
+	return res
+}
+
+// ChargingEnergyWh returns the energy in Wh added to the battery during the given charging session,
+// by integrating the voltage over the change in the charge counter. Only the entries in the session
+// that report both the voltage and charge are used, and the voltage between two such entries is taken
+// as their average.
+func ChargingEnergyWh(entries []*BatteryHistoryV2Entry, s ChargingSession) float64 {
+	var wh float64
+	var prev *BatteryHistoryV2Entry
+	for _, e := range entries {
+		if e.TimestampMs < s.StartMs || e.TimestampMs > s.EndMs || !e.IsSet("volt") || !e.IsSet("charge") {
+			continue
+		}
+		if prev != nil {
+			// Voltage is in mV and the charge is in mAh.
+			v := float64(prev.Voltage+e.Voltage) / 2 / 1000
+			ah := float64(e.ChargeMicroAh-prev.ChargeMicroAh) / 1000
+			wh += v * ah
+		}
+		prev = e
+	}
+	return wh
+}
+
+// DrainRate returns the average rate in mAh per hour the battery discharged at, between the given
+// timestamps (in ms, inclusive). Only the periods between consecutive charge readings during which the
+// device was discharging are used, so time spent charging doesn't dilute the rate. The status is
+// carried forward from entries before the start. It returns 0 if there is no discharging period
+// with charge readings in the range.
+func DrainRate(entries []*BatteryHistoryV2Entry, start, end int64) float64 {
+	var status string
+	var prev *BatteryHistoryV2Entry
+	var mah float64
+	var ms int64
+	for _, e := range entries {
+		if e.TimestampMs > end {
+			break
+		}
+		discharging := status == "discharging"
+		if e.Status != "" {
+			status = e.Status
+		}
+		if e.TimestampMs < start || !e.IsSet("charge") {
+			continue
+		}
+		if prev != nil && discharging {
+			mah += float64(prev.ChargeMicroAh - e.ChargeMicroAh)
+			ms += e.TimestampMs - prev.TimestampMs
+		}
+		prev = e
+	}
+	if ms <= 0 {
+		return 0
+	}
+	return mah / (float64(ms) / float64(time.Hour/time.Millisecond))
+}
+
+// ScreenOnPercent returns the percentage of the capture, from the first to the last entry, during
+// which the screen was on, based on the Screen intervals. It returns 0 if the capture has no duration.
+func ScreenOnPercent(entries []*BatteryHistoryV2Entry) float64 {
+	if len(entries) < 2 {
+		return 0
+	}
+	total := entries[len(entries)-1].TimestampMs - entries[0].TimestampMs
+	if total <= 0 {
+		return 0
+	}
+	var on int64
+	for _, iv := range BuildHistoryV2Intervals(entries) {
+		if iv.Metric == "Screen" {
+			on += iv.End - iv.Start
+		}
+	}
+	return 100 * float64(on) / float64(total)
+}
+
+// CameraAttribution returns the time the camera was on while each app was in the foreground, so the
+// camera use can be attributed to the app. The foreground app and camera state are carried forward
+// from previous entries, which are expected in timestamp order. Camera time with no known foreground
+// app isn't attributed.
+func CameraAttribution(entries []*BatteryHistoryV2Entry) map[string]time.Duration {
+	res := make(map[string]time.Duration)
+	var top string
+	camera := false
+	var prevMs int64
+	for _, e := range entries {
+		if camera && top != "" {
+			res[top] += time.Duration(e.TimestampMs-prevMs) * time.Millisecond
+		}
+		prevMs = e.TimestampMs
+		if e.TopAppLeft != "" && e.TopAppLeft == top {
+			top = ""
+		}
+		if e.TopApp != "" {
+			top = e.TopApp
+		}
+		if _, ok := e.States["camera"]; ok {
+			camera = e.CameraOn
+		}
+	}
+	return res
+}
+
+// AudioAttribution returns the time audio was playing attributed to the app that owned the active
+// media session at the time. sessions are the "Media Session" events found in the logs by the activity
+// parser, whose values are the packages that took over the media session. Audio time before the first
+// session event isn't attributed. Entries are expected in timestamp order.
+func AudioAttribution(entries []*BatteryHistoryV2Entry, sessions []csv.Entry) map[string]time.Duration {
+	sorted := append([]csv.Entry(nil), sessions...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Start < sorted[j].Start
+	})
+	res := make(map[string]time.Duration)
+	audio := false
+	next := 0
+	var owner string
+	var prevMs int64
+	for _, e := range entries {
+		// Split the period since the previous entry at each session change.
+		for ; next < len(sorted) && sorted[next].Start < e.TimestampMs; next++ {
+			if sorted[next].Desc != "Media Session" {
+				continue
+			}
+			if start := sorted[next].Start; start > prevMs {
+				if audio && owner != "" {
+					res[owner] += time.Duration(start-prevMs) * time.Millisecond
+				}
+				prevMs = start
+			}
+			owner = sorted[next].Value
+		}
+		if audio && owner != "" {
+			res[owner] += time.Duration(e.TimestampMs-prevMs) * time.Millisecond
+		}
+		prevMs = e.TimestampMs
+		if on, ok := e.States["audio"]; ok {
+			audio = on
+		}
+	}
+	return res
+}
+
+// TimeToEmpty estimates the time until the battery runs out, from the charge reported by the last
+// entry with a charge reading and the drain rate since the device last started discharging. It returns
+// 0 if the device isn't discharging at the last entry, or the drain rate can't be calculated.
+// Entries are expected in timestamp order.
+func TimeToEmpty(entries []*BatteryHistoryV2Entry) time.Duration {
+	var status string
+	var startMs int64
+	var last *BatteryHistoryV2Entry
+	for _, e := range entries {
+		if e.Status != "" && e.Status != status {
+			if e.Status == "discharging" {
+				startMs = e.TimestampMs
+			}
+			status = e.Status
+		}
+		if e.IsSet("charge") {
+			last = e
+		}
+	}
+	if status != "discharging" || last == nil {
+		return 0
+	}
+	rate := DrainRate(entries, startMs, entries[len(entries)-1].TimestampMs)
+	if rate <= 0 {
+		return 0
+	}
+	return time.Duration(float64(last.ChargeMicroAh) / rate * float64(time.Hour))
+}
+
+// DetectFGSDuringDoze returns the periods during which a process was in the foreground, e.g. running
+// a foreground service, while the device was in deep doze (device_idle=full). Processes shouldn't
+// run in the foreground in deep doze, so this usually indicates a bug. The Value of each returned
+// interval is the package. Intervals are sorted by start time, then by package. The foreground
+// processes and doze mode are carried forward from previous entries, which are expected in timestamp
+// order. A period still in progress ends at the last entry.
+func DetectFGSDuringDoze(entries []*BatteryHistoryV2Entry) []HistoryV2Interval {
+	var res []HistoryV2Interval
+	fg := make(map[string]bool)
+	deep := false
+	// open maps each offending package to the start of its current interval.
+	open := make(map[string]int64)
+	closeInterval := func(pkg string, endMs int64) {
+		res = append(res, HistoryV2Interval{
+			Metric: "Foreground service during doze",
+			Type:   "string",
+			Value:  pkg,
+			Start:  open[pkg],
+			End:    endMs,
+		})
+		delete(open, pkg)
+	}
+	for _, e := range entries {
+		for _, pkg := range e.ForegroundStopped {
+			delete(fg, pkg)
+		}
+		for _, pkg := range e.ForegroundStarted {
+			fg[pkg] = true
+		}
+		if e.IsSet("device_idle") {
+			deep = e.DeviceIdleMode == "full"
+		}
+		for pkg := range open {
+			if !deep || !fg[pkg] {
+				closeInterval(pkg, e.TimestampMs)
+			}
+		}
+		if deep {
+			for pkg := range fg {
+				if _, ok := open[pkg]; !ok {
+					open[pkg] = e.TimestampMs
+				}
+			}
+		}
+	}
+	if len(entries) > 0 {
+		for pkg := range open {
+			closeInterval(pkg, entries[len(entries)-1].TimestampMs)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Start != res[j].Start {
+			return res[i].Start < res[j].Start
+		}
+		return res[i].Value < res[j].Value
+	})
+	return res
+}
+
+// DetectWiFiRoaming returns the periods of WiFi roaming churn, during which the supplicant started
+// associating with an access point at least count times within the given window. Each association
+// attempt starts with the supplicant state changing to associating. Overlapping windows are merged,
+// so each returned interval spans from the first to the last attempt of the churn, and its Value is
+// the number of attempts. Entries are expected in timestamp order.
+func DetectWiFiRoaming(entries []*BatteryHistoryV2Entry, window time.Duration, count int) []HistoryV2Interval {
+	windowMs := window.Nanoseconds() / int64(time.Millisecond)
+	var attempts []int64
+	var state string
+	for _, e := range entries {
+		if !e.IsSet("wifi_suppl") || e.WiFiSupplicantState == state {
+			continue
+		}
+		state = e.WiFiSupplicantState
+		if state == "associating" {
+			attempts = append(attempts, e.TimestampMs)
+		}
+	}
+	if count <= 0 {
+		count = 1
+	}
+	// churn marks the attempts that are part of a window with at least count attempts.
+	churn := make([]bool, len(attempts))
+	j := 0
+	for i := range attempts {
+		if j < i {
+			j = i
+		}
+		for j+1 < len(attempts) && attempts[j+1]-attempts[i] <= windowMs {
+			j++
+		}
+		if j-i+1 >= count {
+			for k := i; k <= j; k++ {
+				churn[k] = true
+			}
+		}
+	}
+	var res []HistoryV2Interval
+	for i := 0; i < len(attempts); i++ {
+		if !churn[i] {
+			continue
+		}
+		start := i
+		for i+1 < len(attempts) && churn[i+1] && attempts[i+1]-attempts[i] <= windowMs {
+			i++
+		}
+		res = append(res, HistoryV2Interval{
+			Metric: "WiFi roaming",
+			Type:   "int",
+			Value:  fmt.Sprint(i - start + 1),
+			Start:  attempts[start],
+			End:    attempts[i],
+		})
+	}
+	return res
+}
+
+// FlashlightWarnings returns the flashlight intervals in the given entries that lasted longer than
+// the threshold, which usually means the torch was left on by mistake.
+func FlashlightWarnings(entries []*BatteryHistoryV2Entry, threshold time.Duration) []HistoryV2Interval {
+	thresholdMs := threshold.Nanoseconds() / int64(time.Millisecond)
+	var res []HistoryV2Interval
+	for _, iv := range BuildHistoryV2Intervals(entries) {
+		if iv.Metric == "Flashlight" && iv.End-iv.Start > thresholdMs {
+			res = append(res, iv)
+		}
+	}
+	return res
+}
+
+// DefaultOverheatThresholdDeciC is the battery temperature, in deci-degrees C, above which the device
+// is considered to be overheating.
+const DefaultOverheatThresholdDeciC = 450
+
+// OverheatingIntervals returns the contiguous periods during which the reported battery temperature
+// was above thresholdDeciC. A period starts at the first entry reporting a temperature above the
+// threshold, and ends at the first entry reporting one at or below it, or at the last entry if the
+// device was still overheating. The Value of each returned interval is the highest temperature
+// reported during it, in deci-degrees C. Entries are expected in timestamp order.
+func OverheatingIntervals(entries []*BatteryHistoryV2Entry, thresholdDeciC int32) []HistoryV2Interval {
+	var res []HistoryV2Interval
+	var cur *HistoryV2Interval
+	var maxTemp int32
+	end := func(endMs int64) {
+		cur.End = endMs
+		cur.Value = fmt.Sprint(maxTemp)
+		res = append(res, *cur)
+		cur = nil
+	}
+	for _, e := range entries {
+		if !e.IsSet("temp") {
+			continue
+		}
+		switch {
+		case e.Temperature > thresholdDeciC && cur == nil:
+			cur = &HistoryV2Interval{Metric: "Overheating", Type: "int", Start: e.TimestampMs}
+			maxTemp = e.Temperature
+		case e.Temperature > thresholdDeciC:
+			if e.Temperature > maxTemp {
+				maxTemp = e.Temperature
+			}
+		case cur != nil:
+			end(e.TimestampMs)
+		}
+	}
+	if cur != nil {
+		end(entries[len(entries)-1].TimestampMs)
+	}
+	return res
+}
+
+// WirelessChargingWarnings returns the periods the device was plugged into a wireless charger during
+// which the battery temperature rose by more than thresholdDeciC, as wireless charging is less
+// efficient than wired and the lost energy heats the device. The rise is measured from the last
+// temperature reported before or at the start of the period to the highest temperature reported
+// during it. The Value of each returned interval is the rise in deci-degrees C. Entries are expected
+// in timestamp order, and a period still in progress ends at the last entry.
+func WirelessChargingWarnings(entries []*BatteryHistoryV2Entry, thresholdDeciC int32) []HistoryV2Interval {
+	var res []HistoryV2Interval
+	wireless, haveTemp, haveStartTemp := false, false, false
+	var startMs int64
+	var temp, startTemp, maxTemp int32
+	end := func(endMs int64) {
+		if haveStartTemp && maxTemp-startTemp > thresholdDeciC {
+			res = append(res, HistoryV2Interval{
+				Metric: "Wireless charging temperature rise",
+				Type:   "int",
+				Value:  fmt.Sprint(maxTemp - startTemp),
+				Start:  startMs,
+				End:    endMs,
+			})
+		}
+		wireless = false
+	}
+	for _, e := range entries {
+		if e.IsSet("plug") && wireless && e.PlugType != "wireless" {
+			end(e.TimestampMs)
+		}
+		if e.IsSet("temp") {
+			temp, haveTemp = e.Temperature, true
+			if wireless && (!haveStartTemp || temp > maxTemp) {
+				if !haveStartTemp {
+					startTemp, haveStartTemp = temp, true
+				}
+				maxTemp = temp
+			}
+		}
+		if e.IsSet("plug") && !wireless && e.PlugType == "wireless" {
+			wireless, startMs = true, e.TimestampMs
+			startTemp, maxTemp, haveStartTemp = temp, temp, haveTemp
+		}
+	}
+	if wireless && len(entries) > 0 {
+		end(entries[len(entries)-1].TimestampMs)
+	}
+	return res
+}
+
+// Warning is a potential problem found in the history.
+type Warning struct {
+	TimestampMs int64
+	// Message describes the problem, e.g. "battery health changed to overheat".
+	Message string
+}
+
+// HealthWarnings returns a warning for each entry at which the battery health changed to a value
+// other than good, e.g. overheat, cold, dead or over_voltage. Unknown health values are not flagged,
+// and health that stays bad over several entries is only flagged once. Entries are expected in
+// timestamp order.
+func HealthWarnings(entries []*BatteryHistoryV2Entry) []Warning {
+	var res []Warning
+	var health string
+	for _, e := range entries {
+		if e.Health == "" || e.Health == health {
+			continue
+		}
+		health = e.Health
+		if health != "good" && health != "unknown" {
+			res = append(res, Warning{
+				TimestampMs: e.TimestampMs,
+				Message:     fmt.Sprintf("battery health changed to %s", health),
+			})
+		}
+	}
+	return res
+}
+
+// WakelockDurations returns the total time each wake lock tag was held in the given entries, which are
+// expected in timestamp order. Wake locks with the same tag held by different UIDs are summed.
+// Wake locks still held after the last entry are counted up to the last entry's timestamp.
+func WakelockDurations(entries []*BatteryHistoryV2Entry) map[string]time.Duration {
+	res := make(map[string]time.Duration)
+	held := make(map[WakeLock]int64)
+	for _, e := range entries {
+		for _, t := range e.WakeLocks {
+			for wl, start := range t.Apply(held, e.TimestampMs) {
+				res[wl.Tag] += time.Duration(e.TimestampMs-start) * time.Millisecond
+			}
+		}
+	}
+	if len(entries) > 0 {
+		end := entries[len(entries)-1].TimestampMs
+		for wl, start := range held {
+			res[wl.Tag] += time.Duration(end-start) * time.Millisecond
+		}
+	}
+	return res
+}
+
+// sagStates are the high power states during which voltage drops are checked for battery sag.
+var sagStates = []string{"camera", "flashlight"}
+
+// DetectVoltageSag returns the timestamps of the entries at which the voltage dropped by more than
+// dropMv from the previous reading while the camera or flashlight was on. Entries are expected in
+// timestamp order.
+func DetectVoltageSag(entries []*BatteryHistoryV2Entry, dropMv int32) []int64 {
+	var res []int64
+	active := make(map[string]bool)
+	var lastVolt int32
+	haveVolt := false
+	for _, e := range entries {
+		for _, s := range sagStates {
+			if on, ok := e.States[s]; ok {
+				active[s] = on
+			}
+		}
+		if !e.IsSet("volt") {
+			continue
+		}
+		loaded := false
+		for _, s := range sagStates {
+			loaded = loaded || active[s]
+		}
+		if haveVolt && loaded && lastVolt-e.Voltage > dropMv {
+			res = append(res, e.TimestampMs)
+		}
+		lastVolt, haveVolt = e.Voltage, true
+	}
+	return res
+}
+
+// MobileRadioDurationsByDataConn returns the total time the mobile radio was active, split by the
+// data connection type (e.g. "lte", "nr") at the time. Radio time before any data_conn is reported
+// is attributed to the empty string. Entries are expected in timestamp order, and radio time still
+// active after the last entry is counted up to the last entry's timestamp.
+func MobileRadioDurationsByDataConn(entries []*BatteryHistoryV2Entry) map[string]time.Duration {
+	res := make(map[string]time.Duration)
+	active := false
+	var conn string
+	var startMs int64
+	for _, e := range entries {
+		on, changed := e.States["mobile_radio"]
+		connChanged := e.IsSet("data_conn") && e.DataConn != conn
+		if active && (connChanged || (changed && !on)) {
+			res[conn] += time.Duration(e.TimestampMs-startMs) * time.Millisecond
+			startMs = e.TimestampMs
+		}
+		if e.IsSet("data_conn") {
+			conn = e.DataConn
+		}
+		if changed {
+			if on && !active {
+				startMs = e.TimestampMs
+			}
+			active = on
+		}
+	}
+	if active && len(entries) > 0 {
+		res[conn] += time.Duration(entries[len(entries)-1].TimestampMs-startMs) * time.Millisecond
+	}
+	return res
+}
@@ -0,0 +1,517 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parseutils
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/battery-historian/csv"
+)
+
+// TestCorrelateStatesWithWakelocks tests attributing active high power states to held wake locks.
+func TestCorrelateStatesWithWakelocks(t *testing.T) {
+	entries := parseV2Lines(t,
+		`01-11 12:00:00.000 075 c4002820 +running`,
+		`01-11 12:00:01.000 075 c4002820 +sensor +wake_lock=u0a55:"FitnessSync"`,
+		`01-11 12:00:02.000 075 c4002820 -wake_lock`,
+		`01-11 12:00:03.000 075 c4002820 -sensor`,
+	)
+	got := CorrelateStatesWithWakelocks(entries)
+	want := []StateAttribution{
+		{
+			TimestampMs: 1768132801000,
+			WakeLocks: map[string][]WakeLock{
+				"sensor": {{UID: "u0a55", Tag: "FitnessSync"}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CorrelateStatesWithWakelocks() = %v, want %v", got, want)
+	}
+}
+
+// TestVoltageEnvelope tests finding the minimum and maximum voltage within a time range.
+func TestVoltageEnvelope(t *testing.T) {
+	entries := parseV2Lines(t,
+		`01-11 12:00:00.000 075 c4002820 volt=4100`,
+		`01-11 12:00:01.000 075 c4002820 volt=3950`,
+		`01-11 12:00:02.000 075 c4002820 +running`,
+		`01-11 12:00:03.000 075 c4002820 volt=4020`,
+		`01-11 12:00:04.000 075 c4002820 volt=3800`,
+	)
+	tests := []struct {
+		desc             string
+		start, end       int64
+		wantMin, wantMax int32
+	}{
+		{
+			desc:    "All entries",
+			start:   1768132800000,
+			end:     1768132804000,
+			wantMin: 3800,
+			wantMax: 4100,
+		},
+		{
+			desc:    "Range excluding the first and last entries",
+			start:   1768132801000,
+			end:     1768132803000,
+			wantMin: 3950,
+			wantMax: 4020,
+		},
+		{
+			desc:  "Range with no voltage readings",
+			start: 1768132802000,
+			end:   1768132802500,
+		},
+	}
+	for _, test := range tests {
+		gotMin, gotMax := VoltageEnvelope(entries, test.start, test.end)
+		if gotMin != test.wantMin || gotMax != test.wantMax {
+			t.Errorf("%v: VoltageEnvelope(%d, %d) = (%d, %d), want (%d, %d)", test.desc, test.start, test.end, gotMin, gotMax, test.wantMin, test.wantMax)
+		}
+	}
+}
+
+// TestSummarize tests the headline statistics produced for a small history block.
+func TestSummarize(t *testing.T) {
+	entries := parseV2Lines(t,
+		`01-11 12:00:00.000 080 c4002820 +running +screen charge=3000`,
+		`01-11 12:00:10.000 079 c4002820 -screen charge=2990 wake_reason=0:"rtc_alarm"`,
+		`01-11 12:00:20.000 078 c4002820 -running charge=2985`,
+		`01-11 12:00:30.000 078 c4002820 +running wake_reason=0:"rtc_alarm"`,
+		`01-11 12:00:35.000 079 c4002820 status=charging charge=2995 wake_reason=0:"wlan_wake"`,
+		`01-11 12:00:40.000 079 c4002820 -running charge=2993`,
+	)
+	want := HistoryV2Summary{
+		ScreenOnMs:           10000,
+		RunningMs:            30000,
+		Wakeups:              3,
+		MinBatteryLevel:      78,
+		MaxBatteryLevel:      80,
+		EstimatedMAhConsumed: 17,
+	}
+	if got := Summarize(entries); got != want {
+		t.Errorf("Summarize() = %+v, want %+v", got, want)
+	}
+	if got, want := SummarizeWakeReasons(entries), map[string]int{"rtc_alarm": 2, "wlan_wake": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SummarizeWakeReasons() = %v, want %v", got, want)
+	}
+}
+
+// TestDetectClockSkew tests that backwards timestamp jumps are only flagged when there's no time marker.
+func TestDetectClockSkew(t *testing.T) {
+	entries := parseV2Lines(t,
+		`01-11 12:00:00.000 075 c4002820 +running`,
+		`01-11 12:00:10.000 075 c4002820 -running`,
+		`01-11 11:59:00.000 075 c4002820 +running`,
+		`01-11 12:00:20.000 075 c4002820 -running`,
+		`01-11 11:00:00.000 075 c4002820 TIME:2026-01-11-11-00-00`,
+		`01-11 11:00:05.000 075 c4002820 +running`,
+	)
+	if got, want := DetectClockSkew(entries), []int{2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DetectClockSkew() = %v, want %v", got, want)
+	}
+}
+
+// TestBucketize tests aggregating entries into fixed windows, including an empty window.
+func TestBucketize(t *testing.T) {
+	entries := parseV2Lines(t,
+		`01-11 12:00:00.000 075 c4002820 +running volt=4000 temp=250`,
+		`01-11 12:00:05.000 075 c4002820 -running +wifi volt=3900`,
+		`01-11 12:00:25.000 075 c4002820 -wifi temp=270`,
+	)
+	got := Bucketize(entries, 10000)
+	want := []HistoryV2Bucket{
+		{
+			StartMs:        1768132800000,
+			EndMs:          1768132810000,
+			NumEntries:     2,
+			AvgVoltage:     3950,
+			AvgTemperature: 250,
+			States:         map[string]bool{"running": true, "wifi": true},
+		},
+		{
+			StartMs:        1768132810000,
+			EndMs:          1768132820000,
+			AvgVoltage:     3950,
+			AvgTemperature: 250,
+			States:         map[string]bool{"wifi": true},
+		},
+		{
+			StartMs:        1768132820000,
+			EndMs:          1768132830000,
+			NumEntries:     1,
+			AvgVoltage:     3950,
+			AvgTemperature: 270,
+			States:         map[string]bool{"wifi": true},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Bucketize() = %+v, want %+v", got, want)
+	}
+}
+
+// TestBuildChargingSessions tests building a charging session from a sequence of statuses.
+func TestBuildChargingSessions(t *testing.T) {
+	entries := parseV2Lines(t,
+		`01-11 12:00:00.000 050 c4002820 status=discharging`,
+		`01-11 12:10:00.000 049 c4002820 status=charging plug=ac`,
+		`01-11 12:20:00.000 060 c4002820 +running`,
+		`01-11 12:40:00.000 080 c4002820 status=full`,
+		`01-11 12:50:00.000 080 c4002820 status=discharging plug=none`,
+		`01-11 13:00:00.000 079 c4002820 -running`,
+	)
+	want := []ChargingSession{
+		{
+			StartMs:    1768133400000,
+			EndMs:      1768135800000,
+			StartLevel: 49,
+			EndLevel:   80,
+			Duration:   40 * time.Minute,
+		},
+	}
+	if got := BuildChargingSessions(entries); !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildChargingSessions() = %+v, want %+v", got, want)
+	}
+}
+
+// TestChargingEnergyWh tests integrating voltage over the change in charge during a charging session.
+func TestChargingEnergyWh(t *testing.T) {
+	entries := parseV2Lines(t,
+		`01-11 12:00:00.000 050 c4002820 status=charging volt=3800 charge=2000`,
+		`01-11 13:00:00.000 075 c4002820 status=discharging volt=4200 charge=3000`,
+	)
+	sessions := BuildChargingSessions(entries)
+	if len(sessions) != 1 {
+		t.Fatalf("BuildChargingSessions() = %v, want 1 session", sessions)
+	}
+	// 1000mAh at an average of 4.0V.
+	if got, want := ChargingEnergyWh(entries, sessions[0]), 4.0; math.Abs(got-want) > 1e-9 {
+		t.Errorf("ChargingEnergyWh() = %v, want %v", got, want)
+	}
+}
+
+// TestFlashlightWarnings tests that only flashlight intervals longer than the threshold are flagged.
+func TestFlashlightWarnings(t *testing.T) {
+	entries := parseV2Lines(t,
+		`01-11 12:00:00.000 075 c4002820 +flashlight`,
+		`01-11 12:00:30.000 075 c4002820 -flashlight`,
+		`01-11 12:05:00.000 074 c4002820 +flashlight`,
+		`01-11 12:25:00.000 070 c4002820 -flashlight`,
+	)
+	want := []HistoryV2Interval{
+		{Metric: "Flashlight", Type: "bool", Value: "true", Start: 1768133100000, End: 1768134300000},
+	}
+	if got := FlashlightWarnings(entries, 10*time.Minute); !reflect.DeepEqual(got, want) {
+		t.Errorf("FlashlightWarnings(10m) = %v, want %v", got, want)
+	}
+}
+
+// TestWakelockDurations tests summing the time each wake lock tag was held.
+func TestWakelockDurations(t *testing.T) {
+	entries := parseV2Lines(t,
+		`01-11 12:00:00.000 075 c4002820 +wake_lock=u0a55:"sync"`,
+		`01-11 12:00:02.000 075 c4002820 -wake_lock=u0a55:"sync"`,
+		`01-11 12:00:10.000 075 c4002820 +wake_lock=u0a55:"sync"`,
+		`01-11 12:00:11.000 075 c4002820 +wake_lock=1000:"*alarm*"`,
+		`01-11 12:00:13.000 075 c4002820 -wake_lock`,
+		`01-11 12:00:20.000 075 c4002820 +wake_lock=1000:"*alarm*"`,
+		`01-11 12:00:25.000 075 c4002820 +running`,
+	)
+	want := map[string]time.Duration{
+		"sync":    5 * time.Second,
+		"*alarm*": 7 * time.Second,
+	}
+	if got := WakelockDurations(entries); !reflect.DeepEqual(got, want) {
+		t.Errorf("WakelockDurations() = %v, want %v", got, want)
+	}
+}
+
+// TestDetectVoltageSag tests that only voltage drops during camera or flashlight use are flagged.
+func TestDetectVoltageSag(t *testing.T) {
+	entries := parseV2Lines(t,
+		`01-11 12:00:00.000 075 c4002820 volt=4100`,
+		`01-11 12:00:01.000 075 c4002820 volt=3900`,
+		`01-11 12:00:02.000 075 c4002820 +camera volt=3890`,
+		`01-11 12:00:03.000 075 c4002820 volt=3700`,
+		`01-11 12:00:04.000 075 c4002820 volt=3680`,
+		`01-11 12:00:05.000 075 c4002820 -camera volt=3500`,
+	)
+	if got, want := DetectVoltageSag(entries, 100), []int64{1768132803000}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DetectVoltageSag(100) = %v, want %v", got, want)
+	}
+}
+
+// TestMobileRadioDurationsByDataConn tests that mobile radio time is split between data connection types.
+func TestMobileRadioDurationsByDataConn(t *testing.T) {
+	entries := parseV2Lines(t,
+		`01-11 12:00:00.000 075 c4002820 data_conn=lte`,
+		`01-11 12:00:10.000 075 c4002820 +mobile_radio`,
+		`01-11 12:00:15.000 075 c4002820 data_conn=nr`,
+		`01-11 12:00:30.000 075 c4002820 -mobile_radio`,
+		`01-11 12:01:00.000 075 c4002820 data_conn=lte`,
+		`01-11 12:01:00.000 075 c4002820 +mobile_radio`,
+		`01-11 12:01:02.000 075 c4002820 data_conn=lte volt=4000`,
+		`01-11 12:01:05.000 075 c4002820 volt=3990`,
+	)
+	want := map[string]time.Duration{
+		"lte": 10 * time.Second,
+		"nr":  15 * time.Second,
+	}
+	if got := MobileRadioDurationsByDataConn(entries); !reflect.DeepEqual(got, want) {
+		t.Errorf("MobileRadioDurationsByDataConn() = %v, want %v", got, want)
+	}
+}
+
+// TestWirelessChargingWarnings tests that only wireless charging periods with a large temperature rise are flagged.
+func TestWirelessChargingWarnings(t *testing.T) {
+	entries := parseV2Lines(t,
+		`01-11 12:00:00.000 050 c4002820 plug=wireless temp=300`,
+		`01-11 12:10:00.000 060 c4002820 temp=360`,
+		`01-11 12:20:00.000 070 c4002820 temp=410`,
+		`01-11 12:30:00.000 075 c4002820 plug=none temp=400`,
+		`01-11 13:00:00.000 070 c4002820 plug=ac temp=320`,
+		`01-11 13:30:00.000 080 c4002820 plug=wireless temp=330`,
+		`01-11 13:40:00.000 085 c4002820 temp=350`,
+		`01-11 13:50:00.000 090 c4002820 plug=none`,
+	)
+	want := []HistoryV2Interval{
+		{Metric: "Wireless charging temperature rise", Type: "int", Value: "110", Start: 1768132800000, End: 1768134600000},
+	}
+	if got := WirelessChargingWarnings(entries, 50); !reflect.DeepEqual(got, want) {
+		t.Errorf("WirelessChargingWarnings(50) = %v, want %v", got, want)
+	}
+}
+
+// TestHealthWarnings tests that transitions to a bad battery health are flagged once.
+func TestHealthWarnings(t *testing.T) {
+	entries := parseV2Lines(t,
+		`01-11 12:00:00.000 075 c4002820 health=good temp=300`,
+		`01-11 12:10:00.000 074 c4002820 health=overheat temp=520`,
+		`01-11 12:11:00.000 074 c4002820 health=overheat temp=530`,
+		`01-11 12:20:00.000 073 c4002820 health=good temp=400`,
+		`01-11 12:30:00.000 073 c4002820 health=unknown`,
+		`01-11 12:40:00.000 073 c4002820 health=cold temp=-50`,
+	)
+	want := []Warning{
+		{TimestampMs: 1768133400000, Message: "battery health changed to overheat"},
+		{TimestampMs: 1768135200000, Message: "battery health changed to cold"},
+	}
+	if got := HealthWarnings(entries); !reflect.DeepEqual(got, want) {
+		t.Errorf("HealthWarnings() = %v, want %v", got, want)
+	}
+}
+
+// TestDrainRate tests the average discharge rate, ignoring time spent charging.
+func TestDrainRate(t *testing.T) {
+	entries := parseV2Lines(t,
+		`01-11 12:00:00.000 075 c4002820 status=discharging charge=3000`,
+		`01-11 14:00:00.000 072 c4002820 charge=2900`,
+		`01-11 14:00:00.000 072 c4002820 status=charging`,
+		`01-11 15:00:00.000 080 c4002820 charge=3200`,
+		`01-11 15:00:00.000 080 c4002820 status=discharging`,
+		`01-11 16:00:00.000 078 c4002820 charge=3100`,
+	)
+	tests := []struct {
+		desc       string
+		start, end int64
+		want       float64
+	}{
+		{"Two sample discharging window", 1768132800000, 1768140000000, 50},
+		{"Charging period ignored", 1768132800000, 1768147200000, 200.0 / 3},
+		{"No discharging period", 1768140000000, 1768143600000, 0},
+	}
+	for _, test := range tests {
+		if got := DrainRate(entries, test.start, test.end); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%v: DrainRate(%d, %d) = %v, want %v", test.desc, test.start, test.end, got, test.want)
+		}
+	}
+}
+
+// TestOverheatingIntervals tests that periods above the temperature threshold are flagged.
+func TestOverheatingIntervals(t *testing.T) {
+	entries := parseV2Lines(t,
+		`01-11 12:00:00.000 075 c4002820 temp=400`,
+		`01-11 12:05:00.000 074 c4002820 temp=460`,
+		`01-11 12:06:00.000 074 c4002820 +running`,
+		`01-11 12:07:00.000 073 c4002820 temp=480`,
+		`01-11 12:10:00.000 073 c4002820 temp=450`,
+		`01-11 12:20:00.000 072 c4002820 temp=470`,
+		`01-11 12:25:00.000 072 c4002820 -running`,
+	)
+	want := []HistoryV2Interval{
+		{Metric: "Overheating", Type: "int", Value: "480", Start: 1768133100000, End: 1768133400000},
+		{Metric: "Overheating", Type: "int", Value: "470", Start: 1768134000000, End: 1768134300000},
+	}
+	if got := OverheatingIntervals(entries, DefaultOverheatThresholdDeciC); !reflect.DeepEqual(got, want) {
+		t.Errorf("OverheatingIntervals(%d) = %v, want %v", DefaultOverheatThresholdDeciC, got, want)
+	}
+}
+
+// TestGPSWakeupCounts tests that only wakeups coinciding with GPS are counted.
+func TestGPSWakeupCounts(t *testing.T) {
+	entries := parseV2Lines(t,
+		`01-11 12:00:00.000 075 c4002820 +running wake_reason=0:"100 rtc_alarm"`,
+		`01-11 12:01:00.000 075 c4002820 +gps wake_reason=0:"200 qcom,smp2p-gps"`,
+		`01-11 12:02:00.000 075 c4002820 wake_reason=0:"100 rtc_alarm"`,
+		`01-11 12:03:00.000 075 c4002820 -gps`,
+		`01-11 12:04:00.000 075 c4002820 wake_reason=0:"100 rtc_alarm"`,
+	)
+	want := map[string]int{
+		"200 qcom,smp2p-gps": 1,
+		"100 rtc_alarm":      1,
+	}
+	if got := GPSWakeupCounts(entries); !reflect.DeepEqual(got, want) {
+		t.Errorf("GPSWakeupCounts() = %v, want %v", got, want)
+	}
+}
+
+// TestScreenOnPercent tests the percentage of the capture the screen was on for.
+func TestScreenOnPercent(t *testing.T) {
+	entries := parseV2Lines(t,
+		`01-11 12:00:00.000 075 c4002820 +running`,
+		`01-11 12:10:00.000 075 c4002820 +screen`,
+		`01-11 12:40:00.000 074 c4002820 -screen`,
+		`01-11 13:30:00.000 073 c4002820 +screen`,
+		`01-11 14:00:00.000 072 c4002820 -running`,
+	)
+	if got := ScreenOnPercent(entries); math.Abs(got-50) > 1e-9 {
+		t.Errorf("ScreenOnPercent() = %v, want 50", got)
+	}
+	if got := ScreenOnPercent(entries[:1]); got != 0 {
+		t.Errorf("ScreenOnPercent() for a single entry = %v, want 0", got)
+	}
+}
+
+// TestCameraAttribution tests that camera time is attributed to the app in the foreground.
+func TestCameraAttribution(t *testing.T) {
+	entries := parseV2Lines(t,
+		`01-11 12:00:00.000 075 c4002820 +top=u0a50:"com.android.launcher"`,
+		`01-11 12:01:00.000 075 c4002820 -top=u0a50:"com.android.launcher" +top=u0a123:"com.android.camera"`,
+		`01-11 12:01:05.000 075 c4002820 +camera`,
+		`01-11 12:03:05.000 075 c4002820 -top=u0a123:"com.android.camera" +top=u0a99:"com.example.chat"`,
+		`01-11 12:03:35.000 075 c4002820 -camera`,
+		`01-11 12:05:00.000 075 c4002820 +running`,
+	)
+	want := map[string]time.Duration{
+		"com.android.camera": 2 * time.Minute,
+		"com.example.chat":   30 * time.Second,
+	}
+	if got := CameraAttribution(entries); !reflect.DeepEqual(got, want) {
+		t.Errorf("CameraAttribution() = %v, want %v", got, want)
+	}
+}
+
+// TestAudioAttribution tests that audio time is attributed to the app owning the media session.
+func TestAudioAttribution(t *testing.T) {
+	entries := parseV2Lines(t,
+		`01-11 12:00:00.000 075 c4002820 +audio`,
+		`01-11 12:02:00.000 075 c4002820 +running`,
+		`01-11 12:05:00.000 075 c4002820 -audio`,
+		`01-11 12:10:00.000 075 c4002820 +audio`,
+		`01-11 12:12:00.000 075 c4002820 -audio`,
+	)
+	sessions := []csv.Entry{
+		{Desc: "Media Session", Type: "service", Start: 1768132860000, Value: "com.example.music"},
+		{Desc: "Network Up", Type: "service", Start: 1768132900000, Value: "wlan0"},
+		{Desc: "Media Session", Type: "service", Start: 1768133460000, Value: "com.example.podcasts"},
+	}
+	want := map[string]time.Duration{
+		"com.example.music":    4*time.Minute + time.Minute,
+		"com.example.podcasts": time.Minute,
+	}
+	if got := AudioAttribution(entries, sessions); !reflect.DeepEqual(got, want) {
+		t.Errorf("AudioAttribution() = %v, want %v", got, want)
+	}
+}
+
+// TestTimeToEmpty tests the estimate of the time until the battery runs out.
+func TestTimeToEmpty(t *testing.T) {
+	tests := []struct {
+		desc  string
+		lines []string
+		want  time.Duration
+	}{
+		{
+			desc: "Discharging at 100 mAh per hour",
+			lines: []string{
+				`01-11 10:00:00.000 090 c4002820 status=charging charge=3600`,
+				`01-11 12:00:00.000 080 c4002820 status=discharging charge=3200`,
+				`01-11 13:00:00.000 077 c4002820 charge=3100`,
+				`01-11 14:00:00.000 075 c4002820 charge=3000`,
+			},
+			want: 30 * time.Hour,
+		},
+		{
+			desc: "Charging",
+			lines: []string{
+				`01-11 12:00:00.000 080 c4002820 status=discharging charge=3200`,
+				`01-11 13:00:00.000 077 c4002820 charge=3100`,
+				`01-11 13:30:00.000 077 c4002820 status=charging`,
+			},
+		},
+	}
+	for _, test := range tests {
+		if got := TimeToEmpty(parseV2Lines(t, test.lines...)); got != test.want {
+			t.Errorf("%v: TimeToEmpty() = %v, want %v", test.desc, got, test.want)
+		}
+	}
+}
+
+// TestDetectFGSDuringDoze tests that processes in the foreground during deep doze are flagged.
+func TestDetectFGSDuringDoze(t *testing.T) {
+	entries := parseV2Lines(t,
+		`01-11 12:00:00.000 075 c4002820 +fg=u0a99:"com.example.tracker" device_idle=light`,
+		`01-11 12:10:00.000 075 c4002820 device_idle=full`,
+		`01-11 12:15:00.000 075 c4002820 +fg=u0a50:"com.example.music"`,
+		`01-11 12:20:00.000 075 c4002820 -fg=u0a50:"com.example.music"`,
+		`01-11 12:30:00.000 074 c4002820 device_idle=off`,
+		`01-11 12:40:00.000 074 c4002820 -fg=u0a99:"com.example.tracker"`,
+	)
+	want := []HistoryV2Interval{
+		{Metric: "Foreground service during doze", Type: "string", Value: "com.example.tracker", Start: 1768133400000, End: 1768134600000},
+		{Metric: "Foreground service during doze", Type: "string", Value: "com.example.music", Start: 1768133700000, End: 1768134000000},
+	}
+	if got := DetectFGSDuringDoze(entries); !reflect.DeepEqual(got, want) {
+		t.Errorf("DetectFGSDuringDoze() = %v, want %v", got, want)
+	}
+}
+
+// TestDetectWiFiRoaming tests that rapid association attempts are flagged as roaming churn.
+func TestDetectWiFiRoaming(t *testing.T) {
+	entries := parseV2Lines(t,
+		`01-11 12:00:00.000 075 c4002820 wifi_suppl=associating`,
+		`01-11 12:00:01.000 075 c4002820 wifi_suppl=completed`,
+		`01-11 12:30:00.000 075 c4002820 wifi_suppl=associating`,
+		`01-11 12:30:01.000 075 c4002820 wifi_suppl=completed`,
+		`01-11 12:30:20.000 075 c4002820 wifi_suppl=associating`,
+		`01-11 12:30:20.500 075 c4002820 wifi_suppl=associating`,
+		`01-11 12:30:21.000 075 c4002820 wifi_suppl=completed`,
+		`01-11 12:30:40.000 075 c4002820 wifi_suppl=associating`,
+		`01-11 12:30:41.000 075 c4002820 wifi_suppl=completed`,
+		`01-11 12:31:10.000 075 c4002820 wifi_suppl=associating`,
+		`01-11 12:31:11.000 075 c4002820 wifi_suppl=completed`,
+		`01-11 13:00:00.000 075 c4002820 wifi_suppl=associating`,
+	)
+	want := []HistoryV2Interval{
+		{Metric: "WiFi roaming", Type: "int", Value: "4", Start: 1768134600000, End: 1768134670000},
+	}
+	if got := DetectWiFiRoaming(entries, time.Minute, 3); !reflect.DeepEqual(got, want) {
+		t.Errorf("DetectWiFiRoaming(1m, 3) = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,343 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parseutils
+
+// battery_history_v2_block.go splits bugreport text into Format 2 history blocks and parses whole blocks.
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/battery-historian/historianutils"
+)
+
+// historyV2HeaderPattern matches the heading that starts a Format 2 history block.
+// e.g. "Battery History [Format: 2] (102% used, 4211KB used of 4096KB, 483 strings using 26KB):"
+var historyV2HeaderPattern = regexp.MustCompile(`^\s*Battery History \[Format: 2\]`)
+
+// base64LinePattern matches a line of a base64 encoded history block. History lines always contain
+// spaces, so they never match.
+var base64LinePattern = regexp.MustCompile(`^[A-Za-z0-9+/]+=*$`)
+
+// historyV2DeltaLinePattern matches a history line whose time is an offset from the last RESET or
+// TIME anchor, capturing the offset and the rest of the line.
+// e.g. "+1s234ms 075 c4002820 +running"
+var historyV2DeltaLinePattern = regexp.MustCompile(`^\s*\+([\dhms]+)\s+(\d+\s+[0-9a-f]+\s+.*)$`)
+
+// HistoryV2Block holds the entries parsed from a single Format 2 history block.
+type HistoryV2Block struct {
+	Entries []*BatteryHistoryV2Entry
+	// Truncated is the malformed final line of the block, if the block was cut off mid-write.
+	Truncated string
+	Errs      []error
+}
+
+// SplitHistoryV2Blocks splits the given text into the Format 2 history blocks it contains.
+// Each block starts at a "Battery History [Format: 2]" heading and includes the heading line.
+// A block ends at the next heading, at a bugreport section heading, or at the first line that
+// isn't a history line once history lines have started, so the sections that follow the history
+// in a bugreport aren't included. The last line of the text is kept even if it isn't a history
+// line, since it may be a history line cut off mid-write. Lines outside blocks are ignored.
+// Whether a block is base64 encoded is decided per block: a single word is only a history line in
+// a block with no timestamped lines, and only if the block's words decode as base64, so a word
+// such as "Statistics" following the history isn't taken to be part of it.
+func SplitHistoryV2Blocks(text string) []string {
+	var blocks []string
+	var cur []string
+	// encoded is the lines of the current block that may be part of a base64 blob.
+	var encoded []string
+	inBlock, started, timed := false, false, false
+	endBlock := func() {
+		if !timed && len(encoded) > 0 {
+			if _, err := base64.StdEncoding.DecodeString(strings.Join(encoded, "")); err != nil {
+				// Not a base64 blob, so the history ended at the heading.
+				cur = cur[:1]
+			}
+		}
+		blocks = append(blocks, strings.Join(cur, "\n"))
+		inBlock = false
+	}
+	lines := strings.Split(text, "\n")
+	last := len(lines) - 1
+	for last >= 0 && strings.TrimSpace(lines[last]) == "" {
+		last--
+	}
+	for i, line := range lines {
+		if historyV2HeaderPattern.MatchString(line) {
+			if inBlock {
+				endBlock()
+			}
+			inBlock, started, timed = true, false, false
+			cur, encoded = []string{line}, nil
+			continue
+		}
+		if !inBlock {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+		case historyLinePatternV2.MatchString(trimmed) || historyV2DeltaLinePattern.MatchString(trimmed):
+			started, timed = true, true
+		case !timed && base64LinePattern.MatchString(trimmed):
+			started = true
+			encoded = append(encoded, trimmed)
+		case strings.HasPrefix(trimmed, "------") || strings.HasPrefix(trimmed, "DUMP OF SERVICE"),
+			started && i != last:
+			endBlock()
+			continue
+		}
+		cur = append(cur, line)
+	}
+	if inBlock {
+		endBlock()
+	}
+	return blocks
+}
+
+// ParseHistoryV2Block parses every Format 2 history line in the given block.
+// The block heading and blank lines are skipped. Errors encountered during parsing will be
+// collected into an errors slice and will continue parsing remaining lines.
+// Lines may give their time as an offset from the last RESET or TIME anchor instead of a
+// timestamp, e.g. "+1s234ms 075 c4002820 +running". Such lines found before any anchor are
+// reported as errors, since their time is undefined.
+// A malformed final line is assumed to be the result of the bugreport being cut off
+// mid-write, so it is recorded in Truncated rather than reported as an error.
+// Blocks embedded as a base64 blob, optionally gzip compressed, are decoded before parsing.
+// Platform specific states aren't recognized; use ParseHistoryV2BlockWithContext for those.
+func ParseHistoryV2Block(block string) *HistoryV2Block {
+	return ParseHistoryV2BlockWithContext(ParseContext{}, block)
+}
+
+// ParseHistoryV2BlockWithContext is the same as ParseHistoryV2Block, but additionally records the
+// states specific to the context's platform in PlatformStates.
+func ParseHistoryV2BlockWithContext(ctx ParseContext, block string) *HistoryV2Block {
+	res := &HistoryV2Block{}
+	if decoded, ok, err := decodeBase64HistoryV2(block); err != nil {
+		res.Errs = append(res.Errs, err)
+	} else if ok {
+		block = decoded
+	}
+	lines := strings.Split(block, "\n")
+	r := &historyV2Resolver{ctx: ctx}
+	last := len(lines) - 1
+	for last >= 0 && strings.TrimSpace(lines[last]) == "" {
+		last--
+	}
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" || historyV2HeaderPattern.MatchString(line) {
+			continue
+		}
+		line, err := r.resolveTime(line)
+		if err != nil {
+			res.Errs = append(res.Errs, fmt.Errorf("line %d: %v", i+1, err))
+			continue
+		}
+		e, err := r.parse(line)
+		if err != nil {
+			if i == last {
+				res.Truncated = line
+				continue
+			}
+			res.Errs = append(res.Errs, fmt.Errorf("line %d: %v", i+1, err))
+			continue
+		}
+		res.Entries = append(res.Entries, e)
+	}
+	return res
+}
+
+// historyV2Resolver holds the state carried forward between the lines of a history, which is
+// needed to resolve delta times and to derive the values that depend on earlier lines.
+// It is shared by ParseHistoryV2Block and HistoryV2Parser, so both give the same entries.
+type historyV2Resolver struct {
+	ctx ParseContext
+	// anchor is the last entry with a RESET or TIME marker, which delta times are relative to.
+	anchor *BatteryHistoryV2Entry
+	// fullCharge is the full charge capacity in mAh the state of charge is relative to.
+	fullCharge int64
+	// dataConn is the last reported data connection.
+	dataConn string
+	// radioActive is the last reported mobile_radio state.
+	radioActive bool
+	// airplaneMode is the last reported airplane_mode state.
+	airplaneMode bool
+	// phoneOff is whether the last reported phone_state was off.
+	phoneOff bool
+}
+
+// resolveTime rewrites a line whose time is an offset from the last RESET or TIME anchor to have
+// a full timestamp. Other lines are returned unchanged.
+func (r *historyV2Resolver) resolveTime(line string) (string, error) {
+	m := historyV2DeltaLinePattern.FindStringSubmatch(line)
+	if m == nil {
+		return line, nil
+	}
+	// The line's time is an offset from the last RESET/TIME anchor, so it can't be resolved
+	// without one.
+	if r.anchor == nil {
+		return "", fmt.Errorf("delta time %q found before any RESET or TIME anchor", "+"+m[1])
+	}
+	ms, err := historianutils.ParseDurationWithDays(m[1])
+	if err != nil {
+		return "", fmt.Errorf("invalid delta time %q: %v", "+"+m[1], err)
+	}
+	ts := r.anchor.Timestamp.Add(time.Duration(ms) * time.Millisecond)
+	return ts.Format("01-02 15:04:05.000") + " " + m[2], nil
+}
+
+// parse parses a line with a full timestamp, and updates the carried forward state.
+func (r *historyV2Resolver) parse(line string) (*BatteryHistoryV2Entry, error) {
+	e, err := ParseHistoryV2LineWithContext(r.ctx, line)
+	if err != nil {
+		return nil, err
+	}
+	if e.TimeChanged {
+		r.anchor = e
+	}
+	r.deriveSoC(e)
+	r.deriveRadioActivity(e)
+	r.deriveAirplaneMode(e)
+	return e, nil
+}
+
+// deriveAirplaneMode sets whether the entry is in airplane mode, either because airplane_mode is
+// on, or because the phone radio is off. A data connection of "none" isn't enough, since the device
+// may be using WiFi only.
+func (r *historyV2Resolver) deriveAirplaneMode(e *BatteryHistoryV2Entry) {
+	if on, ok := e.States["airplane_mode"]; ok {
+		r.airplaneMode = on
+	}
+	if e.IsSet("phone_state") {
+		r.phoneOff = e.PhoneState == "off"
+	}
+	e.AirplaneMode = r.airplaneMode || r.phoneOff
+}
+
+// deriveRadioActivity sets the radio activity of the entry, carrying the data connection and the
+// mobile_radio state forward from previous entries. A data connection of "none" means there is no
+// connection.
+func (r *historyV2Resolver) deriveRadioActivity(e *BatteryHistoryV2Entry) {
+	if e.IsSet("data_conn") {
+		r.dataConn = e.DataConn
+	}
+	if on, ok := e.States["mobile_radio"]; ok {
+		r.radioActive = on
+	}
+	switch {
+	case r.radioActive:
+		e.RadioActivity = RadioActive
+	case r.dataConn != "" && r.dataConn != "none":
+		e.RadioActivity = RadioIdle
+	default:
+		e.RadioActivity = RadioOff
+	}
+}
+
+// deriveSoC sets the state of charge of an entry that reports the charge counter, relative to the
+// full charge capacity. The capacity is anchored by charge_full, or by the charge counter reported at
+// a battery level of 100%, since the counter is reset to the full capacity when the battery is full.
+// Entries before the first anchor are left unset.
+func (r *historyV2Resolver) deriveSoC(e *BatteryHistoryV2Entry) {
+	switch {
+	case e.IsSet("charge_full") && e.ChargeFull > 0:
+		r.fullCharge = e.ChargeFull
+	case e.BatteryPercent == 100 && e.IsSet("charge") && e.ChargeMicroAh > 0:
+		r.fullCharge = e.ChargeMicroAh
+	}
+	if r.fullCharge > 0 && e.IsSet("charge") {
+		e.SoC = 100 * float64(e.ChargeMicroAh) / float64(r.fullCharge)
+	}
+}
+
+// decodeBase64HistoryV2 decodes the history lines of a block that was embedded as a base64 blob,
+// optionally gzip compressed. The heading line, if present, is kept. It returns false if the block
+// isn't base64 encoded.
+func decodeBase64HistoryV2(block string) (string, bool, error) {
+	var heading string
+	var encoded []string
+	for _, line := range strings.Split(block, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case heading == "" && len(encoded) == 0 && historyV2HeaderPattern.MatchString(line):
+			heading = line
+		case base64LinePattern.MatchString(line):
+			encoded = append(encoded, line)
+		default:
+			return "", false, nil
+		}
+	}
+	if len(encoded) == 0 {
+		return "", false, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.Join(encoded, ""))
+	if err != nil {
+		return "", false, fmt.Errorf("could not decode base64 history: %v", err)
+	}
+	// Compressed blobs are identified by the gzip magic number.
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return "", false, fmt.Errorf("could not decompress base64 history: %v", err)
+		}
+		if data, err = io.ReadAll(r); err != nil {
+			return "", false, fmt.Errorf("could not decompress base64 history: %v", err)
+		}
+	}
+	if heading != "" {
+		return heading + "\n" + string(data), true, nil
+	}
+	return string(data), true, nil
+}
+
+// ParseHistoryV2BlocksConcurrently parses each of the given blocks on a separate goroutine,
+// with at most maxConcurrency blocks being parsed at once. If maxConcurrency is not positive,
+// the number of CPUs is used. The results are returned in the same order as the given blocks,
+// regardless of the order the goroutines finish in.
+func ParseHistoryV2BlocksConcurrently(blocks []string, maxConcurrency int) []*HistoryV2Block {
+	return ParseHistoryV2BlocksConcurrentlyWithContext(ParseContext{}, blocks, maxConcurrency)
+}
+
+// ParseHistoryV2BlocksConcurrentlyWithContext is the same as ParseHistoryV2BlocksConcurrently, but
+// parses each block with ParseHistoryV2BlockWithContext using the given context.
+func ParseHistoryV2BlocksConcurrentlyWithContext(ctx ParseContext, blocks []string, maxConcurrency int) []*HistoryV2Block {
+	if maxConcurrency <= 0 {
+		maxConcurrency = runtime.NumCPU()
+	}
+	res := make([]*HistoryV2Block, len(blocks))
+	sem := make(chan struct{}, maxConcurrency)
+	var wg sync.WaitGroup
+	for i, b := range blocks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, b string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			// Each goroutine writes to its own index, so no locking is needed.
+			res[i] = ParseHistoryV2BlockWithContext(ctx, b)
+		}(i, b)
+	}
+	wg.Wait()
+	return res
+}
@@ -0,0 +1,388 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parseutils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSplitHistoryV2Blocks tests splitting text into Format 2 history blocks.
+func TestSplitHistoryV2Blocks(t *testing.T) {
+	input := strings.Join([]string{
+		`------ CHECKIN BATTERYSTATS ------`,
+		`Battery History [Format: 2] (10% used):`,
+		`01-11 12:11:14.405 075 c4002820 status=discharging`,
+		`Battery History [Format: 2] (5% used):`,
+		`01-11 13:00:00.000 074 c4002820 status=charging`,
+	}, "\n")
+
+	got := SplitHistoryV2Blocks(input)
+	if len(got) != 2 {
+		t.Fatalf("SplitHistoryV2Blocks() returned %d blocks, want 2", len(got))
+	}
+	if !strings.Contains(got[0], "discharging") || !strings.Contains(got[1], "status=charging") {
+		t.Errorf("SplitHistoryV2Blocks() = %q, blocks not split at headings", got)
+	}
+}
+
+// TestSplitHistoryV2BlocksEnd tests that blocks end before the sections following the history.
+func TestSplitHistoryV2BlocksEnd(t *testing.T) {
+	tests := []struct {
+		desc  string
+		input []string
+		want  []string
+	}{
+		{
+			desc: "Section heading",
+			input: []string{
+				`Battery History [Format: 2] (10% used):`,
+				`01-11 12:11:14.405 075 c4002820 status=discharging`,
+				``,
+				`------ DUMPSYS (/system/bin/dumpsys) ------`,
+				`DUMP OF SERVICE alarm:`,
+			},
+			want: []string{"Battery History [Format: 2] (10% used):\n01-11 12:11:14.405 075 c4002820 status=discharging\n"},
+		},
+		{
+			desc: "Non-history line",
+			input: []string{
+				`Battery History [Format: 2] (10% used):`,
+				`01-11 12:11:14.405 075 c4002820 TIME:2026-01-11-12-11-14 status=discharging`,
+				`+1s000ms 075 c4002820 +running`,
+				`Per-PID Stats:`,
+				`  PID 1234 wake time: +1s0ms`,
+			},
+			want: []string{"Battery History [Format: 2] (10% used):\n01-11 12:11:14.405 075 c4002820 TIME:2026-01-11-12-11-14 status=discharging\n+1s000ms 075 c4002820 +running"},
+		},
+		{
+			desc: "Empty block",
+			input: []string{
+				`Battery History [Format: 2] (0% used):`,
+				`------ DUMPSYS (/system/bin/dumpsys) ------`,
+				`01-11 12:11:14.405 075 c4002820 status=discharging`,
+			},
+			want: []string{"Battery History [Format: 2] (0% used):"},
+		},
+		{
+			desc: "Word following the history",
+			input: []string{
+				`Battery History [Format: 2] (10% used):`,
+				`01-11 12:11:14.405 075 c4002820 status=discharging`,
+				`Statistics`,
+				`  Time on battery: 1h 0m 0s 0ms`,
+			},
+			want: []string{"Battery History [Format: 2] (10% used):\n01-11 12:11:14.405 075 c4002820 status=discharging"},
+		},
+		{
+			desc: "Word following an empty block",
+			input: []string{
+				`Battery History [Format: 2] (0% used):`,
+				`Statistics`,
+				`  Time on battery: 1h 0m 0s 0ms`,
+			},
+			want: []string{"Battery History [Format: 2] (0% used):"},
+		},
+		{
+			desc: "Base64 block",
+			input: []string{
+				`Battery History [Format: 2] (base64):`,
+				`MDEtMTEgMTI6MTE6MTQuNDA1IDA3NSBjNDAwMjgy`,
+				`MCBzdGF0dXM9ZGlzY2hhcmdpbmc=`,
+				`Statistics:`,
+				`  Time on battery: 1h 0m 0s 0ms`,
+			},
+			want: []string{"Battery History [Format: 2] (base64):\nMDEtMTEgMTI6MTE6MTQuNDA1IDA3NSBjNDAwMjgy\nMCBzdGF0dXM9ZGlzY2hhcmdpbmc="},
+		},
+		{
+			desc: "Line cut off at the end",
+			input: []string{
+				`Battery History [Format: 2] (10% used):`,
+				`01-11 12:11:14.405 075 c4002820 status=discharging`,
+				`01-11 12:11:1`,
+			},
+			want: []string{"Battery History [Format: 2] (10% used):\n01-11 12:11:14.405 075 c4002820 status=discharging\n01-11 12:11:1"},
+		},
+	}
+	for _, test := range tests {
+		got := SplitHistoryV2Blocks(strings.Join(test.input, "\n"))
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: SplitHistoryV2Blocks() = %q, want %q", test.desc, got, test.want)
+			continue
+		}
+		if res := ParseHistoryV2Block(got[0]); len(res.Errs) != 0 {
+			t.Errorf("%v: ParseHistoryV2Block() unexpected errors: %v", test.desc, res.Errs)
+		}
+	}
+}
+
+// TestParseHistoryV2Block tests parsing all lines of a Format 2 history block.
+func TestParseHistoryV2Block(t *testing.T) {
+	block := strings.Join([]string{
+		`Battery History [Format: 2] (10% used):`,
+		`01-11 12:11:14.405 075 c4002820 status=discharging`,
+		``,
+		`not a history line`,
+		`01-11 12:11:15.396 075 84002820 +running`,
+	}, "\n")
+
+	got := ParseHistoryV2Block(block)
+	if len(got.Entries) != 2 {
+		t.Errorf("ParseHistoryV2Block() returned %d entries, want 2", len(got.Entries))
+	}
+	if len(got.Errs) != 1 {
+		t.Errorf("ParseHistoryV2Block() returned errors %v, want 1 error", got.Errs)
+	}
+}
+
+// TestParseHistoryV2BlocksConcurrently tests that concurrently parsed blocks are returned in their original order.
+func TestParseHistoryV2BlocksConcurrently(t *testing.T) {
+	blocks := []string{
+		strings.Join([]string{
+			`Battery History [Format: 2] (10% used):`,
+			`01-11 12:11:14.405 075 c4002820 status=discharging`,
+			`01-11 12:11:15.396 075 84002820 +running`,
+		}, "\n"),
+		strings.Join([]string{
+			`Battery History [Format: 2] (5% used):`,
+			`01-11 13:00:00.000 074 c4002820 status=charging`,
+		}, "\n"),
+	}
+
+	// Repeat to give the goroutines a chance to finish in different orders.
+	for i := 0; i < 50; i++ {
+		got := ParseHistoryV2BlocksConcurrently(blocks, 2)
+		if len(got) != 2 {
+			t.Fatalf("ParseHistoryV2BlocksConcurrently() returned %d blocks, want 2", len(got))
+		}
+		if len(got[0].Entries) != 2 || got[0].Entries[0].Status != "discharging" {
+			t.Fatalf("ParseHistoryV2BlocksConcurrently() first block = %v, want the discharging block", got[0].Entries)
+		}
+		if len(got[1].Entries) != 1 || got[1].Entries[0].Status != "charging" {
+			t.Fatalf("ParseHistoryV2BlocksConcurrently() second block = %v, want the charging block", got[1].Entries)
+		}
+	}
+}
+
+// TestParseHistoryV2BlockWithContext tests that platform specific states are recognized in blocks
+// parsed with a context.
+func TestParseHistoryV2BlockWithContext(t *testing.T) {
+	block := strings.Join([]string{
+		`Battery History [Format: 2] (10% used):`,
+		`01-11 12:00:00.000 075 c4002820 +body_sensor`,
+	}, "\n")
+	ctx := ParseContext{Platform: PlatformWear}
+	want := map[string]bool{"body_sensor": true}
+
+	got := ParseHistoryV2BlockWithContext(ctx, block)
+	if len(got.Entries) != 1 || !reflect.DeepEqual(got.Entries[0].PlatformStates, want) {
+		t.Errorf("ParseHistoryV2BlockWithContext() = %v, want one entry with PlatformStates %v", got.Entries, want)
+	}
+	for i, b := range ParseHistoryV2BlocksConcurrentlyWithContext(ctx, []string{block, block}, 2) {
+		if len(b.Entries) != 1 || !reflect.DeepEqual(b.Entries[0].PlatformStates, want) {
+			t.Errorf("ParseHistoryV2BlocksConcurrentlyWithContext() block %d = %v, want one entry with PlatformStates %v", i, b.Entries, want)
+		}
+	}
+	if got := ParseHistoryV2Block(block); len(got.Entries) != 1 || len(got.Entries[0].PlatformStates) != 0 {
+		t.Errorf("ParseHistoryV2Block() = %v, want one entry with no PlatformStates", got.Entries)
+	}
+}
+
+// TestParseHistoryV2BlockTruncated tests that a malformed final line is skipped and recorded as truncated.
+func TestParseHistoryV2BlockTruncated(t *testing.T) {
+	block := strings.Join([]string{
+		`Battery History [Format: 2] (10% used):`,
+		`01-11 12:11:14.405 075 c4002820 status=discharging`,
+		`01-11 12:11:1`,
+		``,
+	}, "\n")
+
+	got := ParseHistoryV2Block(block)
+	if len(got.Entries) != 1 {
+		t.Errorf("ParseHistoryV2Block() returned %d entries, want 1", len(got.Entries))
+	}
+	if len(got.Errs) != 0 {
+		t.Errorf("ParseHistoryV2Block() returned unexpected errors: %v", got.Errs)
+	}
+	if got.Truncated != `01-11 12:11:1` {
+		t.Errorf("ParseHistoryV2Block() Truncated = %q, want %q", got.Truncated, `01-11 12:11:1`)
+	}
+}
+
+// TestParseHistoryV2BlockBase64 tests decoding and parsing blocks embedded as base64 blobs.
+func TestParseHistoryV2BlockBase64(t *testing.T) {
+	history := strings.Join([]string{
+		`01-11 12:11:14.405 075 c4002820 status=discharging`,
+		`01-11 12:11:15.396 075 84002820 +running`,
+	}, "\n")
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	if _, err := w.Write([]byte(history)); err != nil {
+		t.Fatalf("gzip Write() unexpected error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("gzip Close() unexpected error: %v", err)
+	}
+	// wrap splits the encoded blob over several lines, as it appears in bug reports.
+	wrap := func(s string) string {
+		var lines []string
+		for len(s) > 40 {
+			lines = append(lines, s[:40])
+			s = s[40:]
+		}
+		return strings.Join(append(lines, s), "\n")
+	}
+
+	tests := []struct {
+		desc  string
+		block string
+	}{
+		{
+			desc:  "Plain base64",
+			block: "Battery History [Format: 2] (base64):\n" + wrap(base64.StdEncoding.EncodeToString([]byte(history))),
+		},
+		{
+			desc:  "Gzip compressed base64",
+			block: "Battery History [Format: 2] (base64):\n" + wrap(base64.StdEncoding.EncodeToString(gz.Bytes())) + "\n",
+		},
+	}
+	for _, test := range tests {
+		got := ParseHistoryV2Block(test.block)
+		if len(got.Errs) != 0 {
+			t.Errorf("%v: ParseHistoryV2Block() returned unexpected errors: %v", test.desc, got.Errs)
+		}
+		if len(got.Entries) != 2 || got.Entries[0].Status != "discharging" || !got.Entries[1].States["running"] {
+			t.Errorf("%v: ParseHistoryV2Block() = %v, want the decoded entries", test.desc, got.Entries)
+		}
+	}
+}
+
+// TestParseHistoryV2BlockSoC tests that the state of charge decreases with the charge after a full charge.
+func TestParseHistoryV2BlockSoC(t *testing.T) {
+	block := strings.Join([]string{
+		`Battery History [Format: 2] (10% used):`,
+		`01-11 12:00:00.000 099 c4002820 status=charging charge=3950`,
+		`01-11 12:10:00.000 100 c4002820 status=full charge=4000`,
+		`01-11 13:00:00.000 090 c4002820 status=discharging charge=3600`,
+		`01-11 14:00:00.000 080 c4002820 charge=3200`,
+		`01-11 15:00:00.000 080 c4002820 charge_full=3800 charge=3040`,
+	}, "\n")
+
+	got := ParseHistoryV2Block(block)
+	want := []float64{0, 100, 90, 80, 80}
+	if len(got.Entries) != len(want) {
+		t.Fatalf("ParseHistoryV2Block() returned %d entries, want %d", len(got.Entries), len(want))
+	}
+	for i, e := range got.Entries {
+		if math.Abs(e.SoC-want[i]) > 1e-9 {
+			t.Errorf("ParseHistoryV2Block() entry %d SoC = %v, want %v", i, e.SoC, want[i])
+		}
+	}
+}
+
+// TestParseHistoryV2BlockDeltas tests that delta times are resolved against the last anchor, and
+// flagged when no anchor has been seen.
+func TestParseHistoryV2BlockDeltas(t *testing.T) {
+	block := strings.Join([]string{
+		`Battery History [Format: 2] (10% used):`,
+		`+1s000ms 075 c4002820 +running`,
+		`01-11 12:00:00.000 075 c4002820 TIME:2026-01-11-12-00-00`,
+		`+1s500ms 075 c4002820 +running`,
+		`01-11 12:00:05.000 075 c4002820 -running`,
+		`+2m 075 c4002820 +gps`,
+	}, "\n")
+
+	got := ParseHistoryV2Block(block)
+	if len(got.Errs) != 1 || !strings.Contains(got.Errs[0].Error(), "line 2: delta time \"+1s000ms\" found before any RESET or TIME anchor") {
+		t.Errorf("ParseHistoryV2Block() errors = %v, want the delta before the anchor flagged", got.Errs)
+	}
+	want := []int64{1768132800000, 1768132801500, 1768132805000, 1768132920000}
+	if len(got.Entries) != len(want) {
+		t.Fatalf("ParseHistoryV2Block() returned %d entries, want %d", len(got.Entries), len(want))
+	}
+	for i, e := range got.Entries {
+		if e.TimestampMs != want[i] {
+			t.Errorf("ParseHistoryV2Block() entry %d TimestampMs = %d, want %d", i, e.TimestampMs, want[i])
+		}
+	}
+	if !got.Entries[1].States["running"] || !got.Entries[3].States["gps"] {
+		t.Errorf("ParseHistoryV2Block() = %v, want the delta lines' states parsed", got.Entries)
+	}
+}
+
+// TestParseHistoryV2BlockRadioActivity tests that a connected but idle radio is distinguished from an active one.
+func TestParseHistoryV2BlockRadioActivity(t *testing.T) {
+	block := strings.Join([]string{
+		`Battery History [Format: 2] (10% used):`,
+		`01-11 12:00:00.000 075 c4002820 status=discharging`,
+		`01-11 12:00:10.000 075 c4002820 data_conn=lte`,
+		`01-11 12:00:20.000 075 c4002820 +mobile_radio`,
+		`01-11 12:00:25.000 075 c4002820 +running`,
+		`01-11 12:00:30.000 075 c4002820 -mobile_radio`,
+		`01-11 12:00:40.000 075 c4002820 data_conn=none`,
+	}, "\n")
+
+	got := ParseHistoryV2Block(block)
+	want := []RadioActivity{RadioOff, RadioIdle, RadioActive, RadioActive, RadioIdle, RadioOff}
+	if len(got.Entries) != len(want) {
+		t.Fatalf("ParseHistoryV2Block() returned %d entries, want %d", len(got.Entries), len(want))
+	}
+	for i, e := range got.Entries {
+		if e.RadioActivity != want[i] {
+			t.Errorf("ParseHistoryV2Block() entry %d RadioActivity = %v, want %v", i, e.RadioActivity, want[i])
+		}
+	}
+}
+
+// TestParseHistoryV2BlockAirplaneMode tests that airplane mode is derived from airplane_mode, and
+// from the phone radio being off, but not from there being no data connection.
+func TestParseHistoryV2BlockAirplaneMode(t *testing.T) {
+	block := strings.Join([]string{
+		`Battery History [Format: 2] (10% used):`,
+		`01-11 12:00:00.000 075 c4002820 phone_state=in data_conn=lte`,
+		`01-11 12:00:01.000 075 c4002820 phone_state=off data_conn=none`,
+		`01-11 12:00:02.000 075 c4002820 +wifi`,
+		`01-11 12:00:03.000 075 c4002820 phone_state=in`,
+		`01-11 12:00:04.000 075 c4002820 data_conn=none`,
+		`01-11 12:00:05.000 075 c4002820 +airplane_mode`,
+		`01-11 12:00:06.000 075 c4002820 -airplane_mode`,
+	}, "\n")
+	got := ParseHistoryV2Block(block)
+	if len(got.Errs) != 0 {
+		t.Fatalf("ParseHistoryV2Block() unexpected errors: %v", got.Errs)
+	}
+	// No data connection isn't airplane mode while the phone radio is on, e.g. when using WiFi only.
+	want := []bool{false, true, true, false, false, true, false}
+	if len(got.Entries) != len(want) {
+		t.Fatalf("ParseHistoryV2Block() returned %d entries, want %d", len(got.Entries), len(want))
+	}
+	for i, e := range got.Entries {
+		if e.AirplaneMode != want[i] {
+			t.Errorf("entry %d: AirplaneMode = %v, want %v", i, e.AirplaneMode, want[i])
+		}
+	}
+	wantIntervals := []HistoryV2Interval{
+		{Metric: "Airplane mode", Type: "bool", Value: "true", Start: 1768132801000, End: 1768132803000},
+		{Metric: "Airplane mode", Type: "bool", Value: "true", Start: 1768132805000, End: 1768132806000},
+	}
+	if got := intervalsFor(BuildHistoryV2Intervals(got.Entries), "Airplane mode"); !reflect.DeepEqual(got, wantIntervals) {
+		t.Errorf("BuildHistoryV2Intervals() = %v, want %v", got, wantIntervals)
+	}
+}
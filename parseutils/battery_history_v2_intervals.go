@@ -0,0 +1,329 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parseutils
+
+// battery_history_v2_intervals.go builds Historian tracks from parsed Format 2 history entries.
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/google/battery-historian/csv"
+)
+
+// HistoryV2Interval is a period of time during which a Format 2 history track held a value.
+type HistoryV2Interval struct {
+	Metric string
+	Type   string
+	Value  string
+	Start  int64
+	End    int64
+}
+
+// CSVEntry converts the interval to a CSV entry with both the start and end time set.
+func (iv HistoryV2Interval) CSVEntry() csv.Entry {
+	return csv.Entry{
+		Desc:  iv.Metric,
+		Type:  iv.Type,
+		Start: iv.Start,
+		End:   iv.End,
+		Value: iv.Value,
+	}
+}
+
+// historyV2Track describes how a Historian track is derived from Format 2 history entries.
+type historyV2Track struct {
+	metric string
+	// typ is the CSV type of the track, e.g. "bool" or "string".
+	typ string
+	// value returns the value of the track set by the entry, and whether the entry changed the track.
+	// An empty value means the track is inactive.
+	value func(e *BatteryHistoryV2Entry) (string, bool)
+}
+
+// stateTrack returns a bool track that follows the +state / -state transitions of the given state.
+func stateTrack(metric, state string) historyV2Track {
+	return historyV2Track{
+		metric: metric,
+		typ:    "bool",
+		value: func(e *BatteryHistoryV2Entry) (string, bool) {
+			on, ok := e.States[state]
+			if !ok {
+				return "", false
+			}
+			if on {
+				return "true", true
+			}
+			return "", true
+		},
+	}
+}
+
+// platformStateTracks returns a bool track for each platform specific state. The tracks follow
+// PlatformStates, so they are only built when the entries were parsed for the matching platform.
+func platformStateTracks() []historyV2Track {
+	var tracks []historyV2Track
+	for _, ps := range platformStates {
+		state := ps.state
+		tracks = append(tracks, historyV2Track{
+			metric: ps.metric,
+			typ:    "bool",
+			value: func(e *BatteryHistoryV2Entry) (string, bool) {
+				on, ok := e.PlatformStates[state]
+				if !ok {
+					return "", false
+				}
+				if on {
+					return "true", true
+				}
+				return "", true
+			},
+		})
+	}
+	return tracks
+}
+
+// historyV2Tracks lists the tracks built from Format 2 history, in output order.
+var historyV2Tracks = append([]historyV2Track{
+	stateTrack(csv.CPURunning, "running"),
+	stateTrack("Screen", "screen"),
+	{
+		metric: "5G NR state",
+		typ:    "string",
+		value: func(e *BatteryHistoryV2Entry) (string, bool) {
+			return e.NRState, e.NRState != ""
+		},
+	},
+	{
+		// The value is the GPS signal quality, which is only reported while GPS is on.
+		metric: "GPS",
+		typ:    "string",
+		value: func(e *BatteryHistoryV2Entry) (string, bool) {
+			on, ok := e.States["gps"]
+			switch {
+			case ok && !on:
+				return "", true
+			case e.GPSSignalQuality != "":
+				return e.GPSSignalQuality, true
+			case ok:
+				return "on", true
+			}
+			return "", false
+		},
+	},
+	{
+		// Battery saver is reported either as a state or as power_save=on|off.
+		metric: "Battery saver",
+		typ:    "bool",
+		value: func(e *BatteryHistoryV2Entry) (string, bool) {
+			_, ok := e.States["battery_saver"]
+			if !ok && !e.IsSet("power_save") {
+				return "", false
+			}
+			if e.BatterySaver {
+				return "true", true
+			}
+			return "", true
+		},
+	},
+	{
+		// The route is set by +audio_output=<route>, and -audio_output ends audio output.
+		metric: "Audio output",
+		typ:    "string",
+		value: func(e *BatteryHistoryV2Entry) (string, bool) {
+			if e.IsSet("audio_output") {
+				return e.AudioOutputRoute, true
+			}
+			if on, ok := e.States["audio_output"]; ok && !on {
+				return "", true
+			}
+			return "", false
+		},
+	},
+	{
+		// The value is the decoder and resolution, if reported, while video is playing.
+		metric: "Video",
+		typ:    "string",
+		value: func(e *BatteryHistoryV2Entry) (string, bool) {
+			on, ok := e.States["video"]
+			if !ok {
+				return "", false
+			}
+			if !on {
+				return "", true
+			}
+			var q []string
+			for _, v := range []string{e.VideoDecoder, e.VideoResolution} {
+				if v != "" {
+					q = append(q, v)
+				}
+			}
+			if len(q) == 0 {
+				return "on", true
+			}
+			return strings.Join(q, ","), true
+		},
+	},
+	{
+		// The provider is reported either as location_provider=<provider> or as a state per provider.
+		metric: "Location provider",
+		typ:    "string",
+		value: func(e *BatteryHistoryV2Entry) (string, bool) {
+			if e.LocationProvider != "" {
+				return e.LocationProvider, true
+			}
+			for state := range locationProviderStates {
+				if on, ok := e.States[state]; ok && !on {
+					return "", true
+				}
+			}
+			return "", false
+		},
+	},
+	{
+		// Airplane mode is also derived from phone_state by ParseHistoryV2Block, so the track can
+		// change whenever either of them is reported.
+		metric: "Airplane mode",
+		typ:    "bool",
+		value: func(e *BatteryHistoryV2Entry) (string, bool) {
+			if _, ok := e.States["airplane_mode"]; !ok && !e.IsSet("phone_state") {
+				return "", false
+			}
+			if e.AirplaneMode {
+				return "true", true
+			}
+			return "", true
+		},
+	},
+	stateTrack("Screen doze", "screen_doze"),
+	stateTrack("Camera", "camera"),
+	stateTrack("Flashlight", "flashlight"),
+	stateTrack("Vibration", "vibration"),
+	stateTrack("Ethernet", "ethernet"),
+	stateTrack("Phone call", "phone_in_call"),
+	stateTrack("Step detector", "step_detector"),
+	stateTrack("USB data", "usb_data"),
+	stateTrack("NFC", "nfc"),
+	stateTrack("WiFi multicast", "wifi_multicast"),
+	stateTrack("Bluetooth", "bluetooth"),
+	stateTrack("BLE scanning", "ble_scan"),
+	stateTrack("BLE advertising", "ble_advertise"),
+	stateTrack("WiFi hotspot", "wifi_ap"),
+}, platformStateTracks()...)
+
+// BuildHistoryV2Intervals converts the transitions found in the given entries into intervals for each track.
+// Entries are expected in timestamp order. Intervals still active after the last entry end at the
+// last entry's timestamp. The returned intervals are sorted by start time.
+func BuildHistoryV2Intervals(entries []*BatteryHistoryV2Entry) []HistoryV2Interval {
+	if len(entries) == 0 {
+		return nil
+	}
+	var intervals []HistoryV2Interval
+	for _, t := range historyV2Tracks {
+		var active *HistoryV2Interval
+		for _, e := range entries {
+			v, ok := t.value(e)
+			if !ok || (active != nil && active.Value == v) {
+				continue
+			}
+			if active != nil {
+				active.End = e.TimestampMs
+				intervals = append(intervals, *active)
+				active = nil
+			}
+			if v != "" {
+				active = &HistoryV2Interval{
+					Metric: t.metric,
+					Type:   t.typ,
+					Value:  v,
+					Start:  e.TimestampMs,
+				}
+			}
+		}
+		if active != nil {
+			active.End = entries[len(entries)-1].TimestampMs
+			intervals = append(intervals, *active)
+		}
+	}
+	sort.SliceStable(intervals, func(i, j int) bool {
+		return intervals[i].Start < intervals[j].Start
+	})
+	return intervals
+}
+
+// HistoryV2CSV returns the tracks built from the given entries in Historian CSV format.
+func HistoryV2CSV(entries []*BatteryHistoryV2Entry) string {
+	var b bytes.Buffer
+	s := csv.NewState(&b, true)
+	for _, iv := range BuildHistoryV2Intervals(entries) {
+		e := iv.CSVEntry()
+		s.Print(e.Desc, e.Type, e.Start, e.End, e.Value, e.Opt)
+	}
+	return b.String()
+}
+
+// CoalesceIntervals merges consecutive intervals of the same metric and value that are separated
+// by a gap of less than minGapMs, to reduce the output for states that rapidly flap on and off.
+// Intervals are only merged if no interval of the same metric with a different value starts
+// between them. The returned intervals are sorted by start time. The given slice is not modified.
+func CoalesceIntervals(intervals []HistoryV2Interval, minGapMs int64) []HistoryV2Interval {
+	sorted := append([]HistoryV2Interval(nil), intervals...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Start < sorted[j].Start
+	})
+	// last maps each metric to the index of its latest interval in res.
+	last := make(map[string]int)
+	var res []HistoryV2Interval
+	for _, iv := range sorted {
+		if i, ok := last[iv.Metric]; ok && res[i].Value == iv.Value && iv.Start-res[i].End < minGapMs {
+			if iv.End > res[i].End {
+				res[i].End = iv.End
+			}
+			continue
+		}
+		last[iv.Metric] = len(res)
+		res = append(res, iv)
+	}
+	return res
+}
+
+// GPSAcquisitionIntervals returns the periods GPS spent searching for a fix and locked on to one,
+// derived from the GPS track. GPS is considered to be searching until the signal quality is good,
+// and while it is poor, since acquiring a fix is the costly phase.
+func GPSAcquisitionIntervals(entries []*BatteryHistoryV2Entry) []HistoryV2Interval {
+	var res []HistoryV2Interval
+	for _, iv := range BuildHistoryV2Intervals(entries) {
+		if iv.Metric != "GPS" {
+			continue
+		}
+		v := "searching"
+		if iv.Value == "good" {
+			v = "locked"
+		}
+		if n := len(res); n > 0 && res[n-1].Value == v && res[n-1].End == iv.Start {
+			res[n-1].End = iv.End
+			continue
+		}
+		res = append(res, HistoryV2Interval{
+			Metric: "GPS acquisition",
+			Type:   "string",
+			Value:  v,
+			Start:  iv.Start,
+			End:    iv.End,
+		})
+	}
+	return res
+}
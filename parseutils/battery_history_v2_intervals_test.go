@@ -0,0 +1,423 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parseutils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/battery-historian/csv"
+)
+
+// parseV2Lines parses the given Format 2 history lines, failing the test on any error.
+func parseV2Lines(t *testing.T, lines ...string) []*BatteryHistoryV2Entry {
+	t.Helper()
+	var entries []*BatteryHistoryV2Entry
+	for _, l := range lines {
+		e, err := ParseHistoryV2Line(l)
+		if err != nil {
+			t.Fatalf("ParseHistoryV2Line(%q) unexpected error: %v", l, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// intervalsFor returns the intervals for the given metric.
+func intervalsFor(intervals []HistoryV2Interval, metric string) []HistoryV2Interval {
+	var res []HistoryV2Interval
+	for _, iv := range intervals {
+		if iv.Metric == metric {
+			res = append(res, iv)
+		}
+	}
+	return res
+}
+
+// TestBuildHistoryV2Intervals tests building track intervals from Format 2 history entries.
+func TestBuildHistoryV2Intervals(t *testing.T) {
+	tests := []struct {
+		desc   string
+		lines  []string
+		metric string
+		want   []HistoryV2Interval
+	}{
+		{
+			desc: "5G NR state changes",
+			lines: []string{
+				`01-11 12:00:00.000 075 c4002820 data_conn=nr nr_state=connected`,
+				`01-11 12:00:01.000 075 c4002820 nr_state=idle`,
+				`01-11 12:00:03.000 075 c4002820 status=discharging`,
+			},
+			metric: "5G NR state",
+			want: []HistoryV2Interval{
+				{Metric: "5G NR state", Type: "string", Value: "connected", Start: 1768132800000, End: 1768132801000},
+				{Metric: "5G NR state", Type: "string", Value: "idle", Start: 1768132801000, End: 1768132803000},
+			},
+		},
+		{
+			desc: "Screen doze toggled",
+			lines: []string{
+				`01-11 12:00:00.000 075 c4002820 -screen +screen_doze`,
+				`01-11 12:00:05.000 075 c4002820 -screen_doze`,
+				`01-11 12:00:06.000 075 c4002820 +screen_doze`,
+				`01-11 12:00:08.000 075 c4002820 status=discharging`,
+			},
+			metric: "Screen doze",
+			want: []HistoryV2Interval{
+				{Metric: "Screen doze", Type: "bool", Value: "true", Start: 1768132800000, End: 1768132805000},
+				{Metric: "Screen doze", Type: "bool", Value: "true", Start: 1768132806000, End: 1768132808000},
+			},
+		},
+		{
+			desc: "Flashlight track independent of camera",
+			lines: []string{
+				`01-11 12:00:00.000 075 c4002820 +flashlight`,
+				`01-11 12:00:05.000 075 c4002820 -flashlight`,
+			},
+			metric: "Flashlight",
+			want: []HistoryV2Interval{
+				{Metric: "Flashlight", Type: "bool", Value: "true", Start: 1768132800000, End: 1768132805000},
+			},
+		},
+		{
+			desc: "No camera track while only the flashlight is on",
+			lines: []string{
+				`01-11 12:00:00.000 075 c4002820 +flashlight`,
+				`01-11 12:00:05.000 075 c4002820 -flashlight`,
+			},
+			metric: "Camera",
+			want:   nil,
+		},
+		{
+			desc: "Vibration toggled",
+			lines: []string{
+				`01-11 12:00:00.000 075 c4002820 +vibration`,
+				`01-11 12:00:00.500 075 c4002820 -vibration`,
+				`01-11 12:00:02.000 075 c4002820 +vibration`,
+				`01-11 12:00:03.000 075 c4002820 -vibration`,
+			},
+			metric: "Vibration",
+			want: []HistoryV2Interval{
+				{Metric: "Vibration", Type: "bool", Value: "true", Start: 1768132800000, End: 1768132800500},
+				{Metric: "Vibration", Type: "bool", Value: "true", Start: 1768132802000, End: 1768132803000},
+			},
+		},
+		{
+			desc: "GPS signal quality changes",
+			lines: []string{
+				`01-11 12:00:00.000 075 c4002820 +gps`,
+				`01-11 12:00:01.000 075 c4002820 gps_signal_quality=poor`,
+				`01-11 12:00:03.000 075 c4002820 gps_signal_quality=good`,
+				`01-11 12:00:05.000 075 c4002820 -gps`,
+			},
+			metric: "GPS",
+			want: []HistoryV2Interval{
+				{Metric: "GPS", Type: "string", Value: "on", Start: 1768132800000, End: 1768132801000},
+				{Metric: "GPS", Type: "string", Value: "poor", Start: 1768132801000, End: 1768132803000},
+				{Metric: "GPS", Type: "string", Value: "good", Start: 1768132803000, End: 1768132805000},
+			},
+		},
+		{
+			desc: "Ethernet toggled",
+			lines: []string{
+				`01-11 12:00:00.000 075 c4002820 +ethernet`,
+				`01-11 12:00:10.000 075 c4002820 -ethernet`,
+			},
+			metric: "Ethernet",
+			want: []HistoryV2Interval{
+				{Metric: "Ethernet", Type: "bool", Value: "true", Start: 1768132800000, End: 1768132810000},
+			},
+		},
+		{
+			desc: "Battery saver toggled by state and key",
+			lines: []string{
+				`01-11 12:00:00.000 075 c4002820 +battery_saver`,
+				`01-11 12:00:10.000 075 c4002820 -battery_saver`,
+				`01-11 12:00:20.000 075 c4002820 power_save=on`,
+				`01-11 12:00:30.000 075 c4002820 power_save=off`,
+			},
+			metric: "Battery saver",
+			want: []HistoryV2Interval{
+				{Metric: "Battery saver", Type: "bool", Value: "true", Start: 1768132800000, End: 1768132810000},
+				{Metric: "Battery saver", Type: "bool", Value: "true", Start: 1768132820000, End: 1768132830000},
+			},
+		},
+		{
+			desc: "Phone call toggled",
+			lines: []string{
+				`01-11 12:00:00.000 075 c4002820 +phone_in_call`,
+				`01-11 12:02:00.000 074 c4002820 -phone_in_call`,
+			},
+			metric: "Phone call",
+			want: []HistoryV2Interval{
+				{Metric: "Phone call", Type: "bool", Value: "true", Start: 1768132800000, End: 1768132920000},
+			},
+		},
+		{
+			desc: "Step detector toggled",
+			lines: []string{
+				`01-11 12:00:00.000 075 c4002820 +step_detector`,
+				`01-11 12:00:30.000 075 c4002820 -step_detector`,
+			},
+			metric: "Step detector",
+			want: []HistoryV2Interval{
+				{Metric: "Step detector", Type: "bool", Value: "true", Start: 1768132800000, End: 1768132830000},
+			},
+		},
+		{
+			desc: "Audio output route changes from speaker to Bluetooth",
+			lines: []string{
+				`01-11 12:00:00.000 075 c4002820 +audio_output=speaker`,
+				`01-11 12:00:10.000 075 c4002820 +audio_output=bt`,
+				`01-11 12:00:30.000 075 c4002820 -audio_output`,
+			},
+			metric: "Audio output",
+			want: []HistoryV2Interval{
+				{Metric: "Audio output", Type: "string", Value: "speaker", Start: 1768132800000, End: 1768132810000},
+				{Metric: "Audio output", Type: "string", Value: "bt", Start: 1768132810000, End: 1768132830000},
+			},
+		},
+		{
+			desc: "USB data toggled",
+			lines: []string{
+				`01-11 12:00:00.000 075 c4002820 +usb_data`,
+				`01-11 12:00:40.000 076 c4002820 -usb_data`,
+			},
+			metric: "USB data",
+			want: []HistoryV2Interval{
+				{Metric: "USB data", Type: "bool", Value: "true", Start: 1768132800000, End: 1768132840000},
+			},
+		},
+		{
+			desc: "NFC toggled",
+			lines: []string{
+				`01-11 12:00:00.000 075 c4002820 +nfc`,
+				`01-11 12:00:02.000 075 c4002820 -nfc`,
+			},
+			metric: "NFC",
+			want: []HistoryV2Interval{
+				{Metric: "NFC", Type: "bool", Value: "true", Start: 1768132800000, End: 1768132802000},
+			},
+		},
+		{
+			desc: "WiFi multicast toggled",
+			lines: []string{
+				`01-11 12:00:00.000 075 c4002820 +wifi_multicast`,
+				`01-11 12:01:00.000 075 c4002820 -wifi_multicast`,
+			},
+			metric: "WiFi multicast",
+			want: []HistoryV2Interval{
+				{Metric: "WiFi multicast", Type: "bool", Value: "true", Start: 1768132800000, End: 1768132860000},
+			},
+		},
+		{
+			desc: "Classic Bluetooth on without a BLE scan",
+			lines: []string{
+				`01-11 12:00:00.000 075 c4002820 +bluetooth`,
+				`01-11 12:00:10.000 075 c4002820 -bluetooth`,
+			},
+			metric: "BLE scanning",
+			want:   nil,
+		},
+		{
+			desc: "Classic Bluetooth track",
+			lines: []string{
+				`01-11 12:00:00.000 075 c4002820 +bluetooth`,
+				`01-11 12:00:05.000 075 c4002820 +ble_scan`,
+				`01-11 12:00:10.000 075 c4002820 -bluetooth -ble_scan`,
+			},
+			metric: "Bluetooth",
+			want: []HistoryV2Interval{
+				{Metric: "Bluetooth", Type: "bool", Value: "true", Start: 1768132800000, End: 1768132810000},
+			},
+		},
+		{
+			desc: "Airplane mode toggled",
+			lines: []string{
+				`01-11 12:00:00.000 075 c4002820 +airplane_mode`,
+				`01-11 13:00:00.000 074 c4002820 -airplane_mode`,
+			},
+			metric: "Airplane mode",
+			want: []HistoryV2Interval{
+				{Metric: "Airplane mode", Type: "bool", Value: "true", Start: 1768132800000, End: 1768136400000},
+			},
+		},
+		{
+			desc: "BLE advertising toggled",
+			lines: []string{
+				`01-11 12:00:00.000 075 c4002820 +ble_advertise`,
+				`01-11 12:00:15.000 075 c4002820 -ble_advertise`,
+			},
+			metric: "BLE advertising",
+			want: []HistoryV2Interval{
+				{Metric: "BLE advertising", Type: "bool", Value: "true", Start: 1768132800000, End: 1768132815000},
+			},
+		},
+		{
+			desc: "Video playback with hardware decoding",
+			lines: []string{
+				`01-11 12:00:00.000 075 c4002820 +video=hw,1080p`,
+				`01-11 12:00:20.000 075 c4002820 -video`,
+				`01-11 12:00:30.000 075 c4002820 +video`,
+				`01-11 12:00:40.000 075 c4002820 -video`,
+			},
+			metric: "Video",
+			want: []HistoryV2Interval{
+				{Metric: "Video", Type: "string", Value: "hw,1080p", Start: 1768132800000, End: 1768132820000},
+				{Metric: "Video", Type: "string", Value: "on", Start: 1768132830000, End: 1768132840000},
+			},
+		},
+		{
+			desc: "Location provider switches from fused to GPS",
+			lines: []string{
+				`01-11 12:00:00.000 075 c4002820 +fused_location`,
+				`01-11 12:00:10.000 075 c4002820 location_provider=gps`,
+				`01-11 12:00:30.000 075 c4002820 -sensor_gps`,
+			},
+			metric: "Location provider",
+			want: []HistoryV2Interval{
+				{Metric: "Location provider", Type: "string", Value: "fused", Start: 1768132800000, End: 1768132810000},
+				{Metric: "Location provider", Type: "string", Value: "gps", Start: 1768132810000, End: 1768132830000},
+			},
+		},
+		{
+			desc: "WiFi hotspot toggled",
+			lines: []string{
+				`01-11 12:00:00.000 075 c4002820 +wifi_ap`,
+				`01-11 12:15:00.000 070 c4002820 -wifi_ap`,
+			},
+			metric: "WiFi hotspot",
+			want: []HistoryV2Interval{
+				{Metric: "WiFi hotspot", Type: "bool", Value: "true", Start: 1768132800000, End: 1768133700000},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := intervalsFor(BuildHistoryV2Intervals(parseV2Lines(t, test.lines...)), test.metric)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("BuildHistoryV2Intervals() %s intervals = %v, want %v", test.metric, got, test.want)
+			}
+		})
+	}
+}
+
+// TestHistoryV2CSV tests the CSV output of Format 2 history tracks.
+func TestHistoryV2CSV(t *testing.T) {
+	entries := parseV2Lines(t,
+		`01-11 12:00:00.000 075 c4002820 nr_state=connected`,
+		`01-11 12:00:01.000 075 c4002820 status=discharging`,
+	)
+	got := HistoryV2CSV(entries)
+	want := "5G NR state,string,1768132800000,1768132801000,connected,"
+	if !strings.Contains(got, want) {
+		t.Errorf("HistoryV2CSV() = %q, want to contain %q", got, want)
+	}
+}
+
+// TestHistoryV2IntervalCSVEntry tests that CSV entries for intervals carry both endpoints.
+func TestHistoryV2IntervalCSVEntry(t *testing.T) {
+	entries := parseV2Lines(t,
+		`01-11 12:00:00.000 075 c4002820 +running wake_reason=0:"100 rtc_alarm"`,
+		`01-11 12:00:02.500 075 c4002820 -running`,
+	)
+	running := intervalsFor(BuildHistoryV2Intervals(entries), csv.CPURunning)
+	if len(running) != 1 {
+		t.Fatalf("BuildHistoryV2Intervals() got %d running intervals, want 1", len(running))
+	}
+	e := running[0].CSVEntry()
+	if e.Start >= e.End {
+		t.Errorf("CSVEntry() Start = %d, End = %d, want Start < End", e.Start, e.End)
+	}
+	if e.Desc != csv.CPURunning || e.End-e.Start != 2500 {
+		t.Errorf("CSVEntry() = %+v, want a 2500ms %s entry", e, csv.CPURunning)
+	}
+}
+
+// TestBuildHistoryV2IntervalsPlatformStates tests that platform specific tracks are built from entries parsed for the platform.
+func TestBuildHistoryV2IntervalsPlatformStates(t *testing.T) {
+	var entries []*BatteryHistoryV2Entry
+	for _, l := range []string{
+		`01-11 12:00:00.000 075 c4002820 +body_sensor`,
+		`01-11 12:00:05.000 075 c4002820 -body_sensor`,
+	} {
+		e, err := ParseHistoryV2LineWithContext(ParseContext{Platform: PlatformWear}, l)
+		if err != nil {
+			t.Fatalf("ParseHistoryV2LineWithContext(%q) unexpected error: %v", l, err)
+		}
+		entries = append(entries, e)
+	}
+	want := []HistoryV2Interval{
+		{Metric: "Body sensor", Type: "bool", Value: "true", Start: 1768132800000, End: 1768132805000},
+	}
+	if got := intervalsFor(BuildHistoryV2Intervals(entries), "Body sensor"); !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildHistoryV2Intervals() = %v, want %v", got, want)
+	}
+	if got := intervalsFor(BuildHistoryV2Intervals(parseV2Lines(t, `01-11 12:00:00.000 075 c4002820 +body_sensor`)), "Body sensor"); len(got) != 0 {
+		t.Errorf("BuildHistoryV2Intervals() without platform = %v, want no body sensor intervals", got)
+	}
+}
+
+// TestCoalesceIntervals tests merging intervals separated by small gaps.
+func TestCoalesceIntervals(t *testing.T) {
+	intervals := BuildHistoryV2Intervals(parseV2Lines(t,
+		`01-11 12:00:00.000 075 c4002820 +running +screen`,
+		`01-11 12:00:01.000 075 c4002820 -running`,
+		`01-11 12:00:01.050 075 c4002820 +running`,
+		`01-11 12:00:02.000 075 c4002820 -running -screen`,
+		`01-11 12:00:05.000 075 c4002820 +running`,
+		`01-11 12:00:06.000 075 c4002820 -running`,
+	))
+	want := []HistoryV2Interval{
+		{Metric: csv.CPURunning, Type: "bool", Value: "true", Start: 1768132800000, End: 1768132802000},
+		{Metric: "Screen", Type: "bool", Value: "true", Start: 1768132800000, End: 1768132802000},
+		{Metric: csv.CPURunning, Type: "bool", Value: "true", Start: 1768132805000, End: 1768132806000},
+	}
+	if got := CoalesceIntervals(intervals, 100); !reflect.DeepEqual(got, want) {
+		t.Errorf("CoalesceIntervals(100) = %v, want %v", got, want)
+	}
+	if got := CoalesceIntervals(intervals, 0); !reflect.DeepEqual(got, intervals) {
+		t.Errorf("CoalesceIntervals(0) = %v, want the intervals unchanged: %v", got, intervals)
+	}
+
+	// Intervals with the same value aren't merged across a different value of the same metric.
+	gps := []HistoryV2Interval{
+		{Metric: "GPS signal quality", Type: "string", Value: "poor", Start: 0, End: 10},
+		{Metric: "GPS signal quality", Type: "string", Value: "good", Start: 10, End: 12},
+		{Metric: "GPS signal quality", Type: "string", Value: "poor", Start: 12, End: 20},
+	}
+	if got := CoalesceIntervals(gps, 5); !reflect.DeepEqual(got, gps) {
+		t.Errorf("CoalesceIntervals(5) = %v, want the intervals unchanged: %v", got, gps)
+	}
+}
+
+// TestGPSAcquisitionIntervals tests splitting GPS usage into searching and locked intervals.
+func TestGPSAcquisitionIntervals(t *testing.T) {
+	entries := parseV2Lines(t,
+		`01-11 12:00:00.000 075 c4002820 +gps`,
+		`01-11 12:00:02.000 075 c4002820 gps_signal_quality=poor`,
+		`01-11 12:00:08.000 075 c4002820 gps_signal_quality=good`,
+		`01-11 12:01:00.000 075 c4002820 -gps`,
+	)
+	want := []HistoryV2Interval{
+		{Metric: "GPS acquisition", Type: "string", Value: "searching", Start: 1768132800000, End: 1768132808000},
+		{Metric: "GPS acquisition", Type: "string", Value: "locked", Start: 1768132808000, End: 1768132860000},
+	}
+	if got := GPSAcquisitionIntervals(entries); !reflect.DeepEqual(got, want) {
+		t.Errorf("GPSAcquisitionIntervals() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parseutils
+
+// battery_history_v2_stream.go supports parsing Format 2 history that is appended to over time.
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HistoryV2Parser incrementally parses Format 2 history. Text can be fed to it in batches as it
+// becomes available, and parsing continues from where the previous batch left off, without
+// reparsing earlier lines. A HistoryV2Parser is not safe for concurrent use.
+type HistoryV2Parser struct {
+	// resolver resolves delta times and derives values from earlier lines, as ParseHistoryV2Block does.
+	resolver historyV2Resolver
+	// lineNum is the number of complete lines seen so far, used in error messages.
+	lineNum int
+	// pending is the start of a line that hadn't been completed at the end of the last batch.
+	pending string
+	entries []*BatteryHistoryV2Entry
+	// states holds the current value of every state seen so far, carried forward between entries.
+	states map[string]bool
+	// held maps the wake locks currently held to the time in ms they were acquired.
+	held map[WakeLock]int64
+	// truncated is the malformed final line found by Flush, if the history was cut off mid-write.
+	truncated string
+}
+
+// NewHistoryV2Parser returns a parser that parses history lines in the given context.
+func NewHistoryV2Parser(ctx ParseContext) *HistoryV2Parser {
+	return &HistoryV2Parser{
+		resolver: historyV2Resolver{ctx: ctx},
+		states:   make(map[string]bool),
+		held:     make(map[WakeLock]int64),
+	}
+}
+
+// Feed parses the complete lines in the given text, and returns the entries parsed from them.
+// Text after the last newline is assumed to be an incomplete line, and is held until the next
+// call to Feed or Flush. Block headings and blank lines are skipped. Delta times are resolved and
+// derived values such as SoC are set in the same way as ParseHistoryV2Block. Errors encountered
+// during parsing will be collected into an errors slice and will continue parsing remaining lines.
+func (p *HistoryV2Parser) Feed(text string) ([]*BatteryHistoryV2Entry, []error) {
+	lines := strings.Split(p.pending+text, "\n")
+	p.pending = lines[len(lines)-1]
+	return p.parseLines(lines[:len(lines)-1], false)
+}
+
+// Flush parses any incomplete line held from the last call to Feed. It should be called once no
+// more text will be fed. As in ParseHistoryV2Block, a malformed final line is assumed to be the
+// result of the history being cut off mid-write, so it is recorded in Truncated rather than
+// reported as an error. Lines ended by a newline are complete, so they are never truncated.
+func (p *HistoryV2Parser) Flush() ([]*BatteryHistoryV2Entry, []error) {
+	line := p.pending
+	p.pending = ""
+	return p.parseLines([]string{line}, true)
+}
+
+// parseLines parses the given lines and updates the carried forward state. If final is true, the
+// last line is the final line of the history, which may have been cut off mid-write.
+func (p *HistoryV2Parser) parseLines(lines []string, final bool) ([]*BatteryHistoryV2Entry, []error) {
+	var entries []*BatteryHistoryV2Entry
+	var errs []error
+	for i, line := range lines {
+		p.lineNum++
+		if strings.TrimSpace(line) == "" || historyV2HeaderPattern.MatchString(line) {
+			continue
+		}
+		line, err := p.resolver.resolveTime(line)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %v", p.lineNum, err))
+			continue
+		}
+		e, err := p.resolver.parse(line)
+		if err != nil {
+			if final && i == len(lines)-1 {
+				p.truncated = line
+				continue
+			}
+			errs = append(errs, fmt.Errorf("line %d: %v", p.lineNum, err))
+			continue
+		}
+		for s, on := range e.States {
+			p.states[s] = on
+		}
+		for _, t := range e.WakeLocks {
+			t.Apply(p.held, e.TimestampMs)
+		}
+		entries = append(entries, e)
+	}
+	p.entries = append(p.entries, entries...)
+	return entries, errs
+}
+
+// Entries returns all the entries parsed so far, in the order they were fed.
+func (p *HistoryV2Parser) Entries() []*BatteryHistoryV2Entry {
+	return p.entries
+}
+
+// Truncated returns the malformed final line found by Flush, or empty if there was none.
+func (p *HistoryV2Parser) Truncated() string {
+	return p.truncated
+}
+
+// ActiveStates returns the states that are active as of the last parsed entry.
+func (p *HistoryV2Parser) ActiveStates() map[string]bool {
+	res := make(map[string]bool)
+	for s, on := range p.states {
+		if on {
+			res[s] = true
+		}
+	}
+	return res
+}
+
+// HeldWakeLocks returns the wake locks held as of the last parsed entry.
+func (p *HistoryV2Parser) HeldWakeLocks() map[WakeLock]bool {
+	res := make(map[WakeLock]bool)
+	for wl := range p.held {
+		res[wl] = true
+	}
+	return res
+}
+
+// Intervals returns the intervals built from all the entries parsed so far.
+func (p *HistoryV2Parser) Intervals() []HistoryV2Interval {
+	return BuildHistoryV2Intervals(p.entries)
+}
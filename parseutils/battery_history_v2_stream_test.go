@@ -0,0 +1,136 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parseutils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/battery-historian/csv"
+)
+
+// TestHistoryV2ParserFeed tests that state is carried over between batches fed to the parser.
+func TestHistoryV2ParserFeed(t *testing.T) {
+	p := NewHistoryV2Parser(ParseContext{})
+
+	// The first batch ends part way through a line.
+	entries, errs := p.Feed("Battery History [Format: 2] (10% used):\n" +
+		"01-11 12:00:00.000 075 c4002820 +running +gps\n" +
+		"01-11 12:00:01.000 075 c4002820 +wake_lock=u0a55:\"sync\"\n" +
+		"01-11 12:00:0")
+	if len(errs) != 0 {
+		t.Fatalf("Feed() first batch unexpected errors: %v", errs)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Feed() first batch returned %d entries, want 2", len(entries))
+	}
+	if want := map[string]bool{"running": true, "gps": true}; !reflect.DeepEqual(p.ActiveStates(), want) {
+		t.Errorf("ActiveStates() after first batch = %v, want %v", p.ActiveStates(), want)
+	}
+
+	entries, errs = p.Feed("2.000 075 c4002820 -running\n" +
+		"bad line\n" +
+		"01-11 12:00:04.000 075 c4002820 -gps")
+	if len(errs) != 1 {
+		t.Errorf("Feed() second batch returned errors %v, want 1 error", errs)
+	}
+	if len(entries) != 1 || entries[0].TimestampMs != 1768132802000 {
+		t.Errorf("Feed() second batch = %v, want the line completed from the first batch", entries)
+	}
+	if want := map[string]bool{"gps": true}; !reflect.DeepEqual(p.ActiveStates(), want) {
+		t.Errorf("ActiveStates() after second batch = %v, want %v", p.ActiveStates(), want)
+	}
+	if want := map[WakeLock]bool{{UID: "u0a55", Tag: "sync"}: true}; !reflect.DeepEqual(p.HeldWakeLocks(), want) {
+		t.Errorf("HeldWakeLocks() after second batch = %v, want %v", p.HeldWakeLocks(), want)
+	}
+
+	if entries, errs = p.Flush(); len(entries) != 1 || len(errs) != 0 {
+		t.Errorf("Flush() = %v, %v, want the final line", entries, errs)
+	}
+	if got := p.ActiveStates(); len(got) != 0 {
+		t.Errorf("ActiveStates() after flush = %v, want none", got)
+	}
+	if got := len(p.Entries()); got != 4 {
+		t.Errorf("Entries() returned %d entries, want 4", got)
+	}
+	want := []HistoryV2Interval{
+		{Metric: csv.CPURunning, Type: "bool", Value: "true", Start: 1768132800000, End: 1768132802000},
+	}
+	if got := intervalsFor(p.Intervals(), csv.CPURunning); !reflect.DeepEqual(got, want) {
+		t.Errorf("Intervals() = %v, want %v", got, want)
+	}
+}
+
+// TestHistoryV2ParserMatchesBlock tests that feeding a block to the parser gives the same entries
+// as parsing the whole block, including resolved delta times and derived values.
+func TestHistoryV2ParserMatchesBlock(t *testing.T) {
+	block := strings.Join([]string{
+		"Battery History [Format: 2] (10% used):",
+		`+1s000ms 075 c4002820 +running`,
+		`01-11 12:00:00.000 100 c4002820 TIME:2026-01-11-12-00-00 charge_full=3000000 charge=3000000 data_conn=lte`,
+		`+1s000ms 099 c4002820 +mobile_radio charge=2900000`,
+		`+2s500ms 097 c4002820 -mobile_radio charge=2910000`,
+		`01-11 12:00:05.000 097 c4002820 data_conn=none`,
+	}, "\n")
+	want := ParseHistoryV2Block(block)
+	if len(want.Errs) != 1 {
+		t.Fatalf("ParseHistoryV2Block() errors = %v, want the delta before the anchor flagged", want.Errs)
+	}
+
+	p := NewHistoryV2Parser(ParseContext{})
+	var errs []error
+	// Feed the block in small batches, so lines are split across batches.
+	for i := 0; i < len(block); i += 7 {
+		_, e := p.Feed(block[i:min(i+7, len(block))])
+		errs = append(errs, e...)
+	}
+	_, e := p.Flush()
+	errs = append(errs, e...)
+	if len(errs) != len(want.Errs) || errs[0].Error() != want.Errs[0].Error() {
+		t.Errorf("Feed() errors = %v, want %v", errs, want.Errs)
+	}
+	if !reflect.DeepEqual(p.Entries(), want.Entries) {
+		t.Errorf("Entries() = %v, want %v", p.Entries(), want.Entries)
+	}
+	if got := p.Entries(); len(got) != 4 || got[1].SoC == 0 || got[1].RadioActivity != RadioActive || got[3].RadioActivity != RadioOff {
+		t.Errorf("Entries() = %v, want SoC and radio activity derived", got)
+	}
+}
+
+// TestHistoryV2ParserTruncated tests that a malformed final line is recorded as truncated, as it is
+// by ParseHistoryV2Block.
+func TestHistoryV2ParserTruncated(t *testing.T) {
+	block := strings.Join([]string{
+		`Battery History [Format: 2] (10% used):`,
+		`01-11 12:00:00.000 075 c4002820 +running`,
+		`01-11 12:00:0`,
+	}, "\n")
+	want := ParseHistoryV2Block(block)
+
+	p := NewHistoryV2Parser(ParseContext{})
+	_, errs := p.Feed(block)
+	_, e := p.Flush()
+	errs = append(errs, e...)
+	if len(errs) != 0 {
+		t.Errorf("Feed() and Flush() unexpected errors: %v", errs)
+	}
+	if got := p.Truncated(); got != want.Truncated || got != `01-11 12:00:0` {
+		t.Errorf("Truncated() = %q, want %q", got, want.Truncated)
+	}
+	if !reflect.DeepEqual(p.Entries(), want.Entries) {
+		t.Errorf("Entries() = %v, want %v", p.Entries(), want.Entries)
+	}
+}